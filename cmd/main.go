@@ -1,63 +1,348 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
-	"os/signal"
+	"strings"
 	"syscall"
 
+	reviewpb "api-gateway/api/review"
 	"api-gateway/config"
+	"api-gateway/internal/auth"
 	customerclient "api-gateway/internal/clients/customer"
 	grpcserver "api-gateway/internal/grpc/server"
+	"api-gateway/internal/health"
+	"api-gateway/internal/http/admin"
+	httpserver "api-gateway/internal/http/server"
+	"api-gateway/internal/logging"
 
 	"api-gateway/internal/infrastructure/kafka"
+	"api-gateway/internal/lifecycle"
+	"api-gateway/internal/outbox"
+	"api-gateway/internal/ratelimit"
 	"api-gateway/internal/service"
+	memorystorage "api-gateway/internal/storage/memory"
 	redisstorage "api-gateway/internal/storage/redis"
+	"api-gateway/internal/telemetry"
+	"api-gateway/internal/tlsconfig"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	cfg, err := config.Load()
+	configPath := flag.String("config", os.Getenv(config.EnvFile), "YAML config file; environment variables override its values")
+	flag.Parse()
+	if args := flag.Args(); len(args) > 0 {
+		if len(args) == 2 && args[0] == "config" && args[1] == "print" {
+			os.Exit(printConfig(*configPath))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\nusage: %s [-config file] [config print]\n", strings.Join(args, " "), os.Args[0])
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		fatal("failed to load config", err)
+	}
+	if err := logging.Setup(os.Stdout, cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("failed to init logging", err)
+	}
+
+	// Tracing
+	shutdownTracing, err := telemetry.Setup(context.Background(), telemetry.Config{
+		ServiceName:  "api-gateway",
+		Exporter:     cfg.TraceExporter,
+		OTLPEndpoint: cfg.TraceOTLPEndpoint,
+		OTLPInsecure: cfg.TraceOTLPInsecure,
+		FilePath:     cfg.TraceFile,
+		SampleRatio:  cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		fatal("failed to init tracing", err)
 	}
 
 	// Redis
-	store, err := redisstorage.New(cfg.RedisAddr)
+	rdb, err := redisstorage.Connect(cfg.RedisAddr)
 	if err != nil {
-		log.Fatalf("failed to init redis storage: %v", err)
+		fatal("failed to init redis storage", err)
+	}
+	settingsCache := redisstorage.NewFromClient(rdb, cacheTTL(cfg.Dynamic))
+	store := settingsCache
+	// background lives until Redis is closed; it bounds pub/sub subscriptions
+	background, stopBackground := context.WithCancel(context.Background())
+	if cfg.CacheL1Size > 0 {
+		// In-process L1 in front of Redis, invalidated across replicas via pub/sub
+		l1 := memorystorage.New(cfg.CacheL1Size, cfg.CacheL1TTL)
+		l1.SetTTL(cacheTTL(cfg.Dynamic))
+		store, err = memorystorage.NewLayered(background, l1, store, redisstorage.NewInvalidationBus(rdb))
+		if err != nil {
+			fatal("failed to init layered cache", err)
+		}
 	}
+	reviews := redisstorage.NewReviewStore(rdb)
+	reviewOutbox := redisstorage.NewOutbox(rdb)
 
 	// Kafka Producer
 	producer, err := kafka.NewProducer(cfg.KafkaBrokers, cfg.KafkaTopic)
 	if err != nil {
-		log.Fatalf("failed to init kafka producer: %v", err)
+		fatal("failed to init kafka producer", err)
 	}
-	defer producer.Close()
-	log.Println("✅ Kafka producer initialized")
+	slog.Info("kafka producer initialized", "topic", cfg.KafkaTopic)
 
 	// Customer Client
-	client, err := customerclient.New(cfg.CustomerServiceAddr)
+	// Перевыпущенные сертификаты подхватываются с диска без рестарта
+	var certReloaders []*tlsconfig.Reloader
+	var customerTLS *tls.Config
+	if cfg.CustomerTLS {
+		certs, err := tlsconfig.New("customer-service", tlsconfig.Files{
+			CertFile: cfg.CustomerTLSCertFile,
+			KeyFile:  cfg.CustomerTLSKeyFile,
+			CAFile:   cfg.CustomerTLSCAFile,
+		}, cfg.TLSReloadInterval)
+		if err != nil {
+			fatal("failed to load customer service TLS", err)
+		}
+		certs.Start()
+		certReloaders = append(certReloaders, certs)
+		customerTLS = certs.ClientConfig(cfg.CustomerTLSServerName)
+	}
+	client, err := customerclient.New(cfg.CustomerServiceAddr, customerclient.Config{
+		ReadTimeout:      cfg.CustomerReadTimeout,
+		WriteTimeout:     cfg.CustomerWriteTimeout,
+		RetryAttempts:    cfg.CustomerRetryAttempts,
+		RetryBackoff:     cfg.CustomerRetryBackoff,
+		RetryMaxBackoff:  cfg.CustomerRetryMaxBackoff,
+		BreakerFailures:  cfg.CustomerBreakerFailures,
+		BreakerCooldown:  cfg.CustomerBreakerCooldown,
+		KeepaliveTime:    cfg.CustomerKeepaliveTime,
+		KeepaliveTimeout: cfg.CustomerKeepaliveTimeout,
+		HealthCheck:      cfg.CustomerHealthCheck,
+		TLS:              customerTLS,
+	})
 	if err != nil {
-		log.Fatalf("failed to init customer client: %v", cfg.CustomerServiceAddr)
+		fatal("failed to init customer client", err, "addr", cfg.CustomerServiceAddr)
 	}
-	defer client.Close()
+
+	// Rate limits for AnalyzeReview, shared by all replicas via Redis
+	// The limiter exists even with every limit off, so a reload can turn limits on
+	limits, err := rateLimits(cfg.Dynamic)
+	if err != nil {
+		fatal("invalid rate limit config", err)
+	}
+	limiter := ratelimit.New(redisstorage.NewRateLimiter(rdb), limits)
 
 	// Service
-	svc := service.New(store, client, producer)
+	svc := service.New(store, client, reviewOutbox, reviews, limiter)
+	svc.SetCacheUpdateMode(cfg.CacheUpdateMode)
+	svc.SetSettingsLocker(redisstorage.NewLocker(rdb))
+
+	// Outbox relay: Redis stream -> Kafka; reviews it can never publish are marked FAILED
+	relay := outbox.NewRelay(reviewOutbox, producer)
+	relay.SetDeadLetterHandler(svc.FailUndeliveredReview)
+	relay.Start()
 
 	// Server
 	srv := grpcserver.New(svc, client)
+	reviewSrv := grpcserver.NewReviewServer(svc)
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	// Logging and metrics go first so rejected calls are logged and counted too
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(grpcserver.LoggingUnaryInterceptor(), grpcserver.MetricsUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(grpcserver.LoggingStreamInterceptor(), grpcserver.MetricsStreamInterceptor()),
+	}
+
+	// TLS for the gRPC listener; the HTTP gateway dials it presenting the same certificate
+	gwCreds := insecure.NewCredentials()
+	if cfg.GRPCTLSCertFile != "" {
+		certs, err := tlsconfig.New("grpc", tlsconfig.Files{
+			CertFile: cfg.GRPCTLSCertFile,
+			KeyFile:  cfg.GRPCTLSKeyFile,
+			CAFile:   cfg.GRPCTLSClientCAFile,
+		}, cfg.TLSReloadInterval)
+		if err != nil {
+			fatal("failed to load gRPC TLS", err)
+		}
+		serverTLS, err := certs.ServerConfig(cfg.GRPCTLSClientAuth)
+		if err != nil {
+			fatal("failed to configure gRPC TLS", err)
+		}
+		certs.Start()
+		certReloaders = append(certReloaders, certs)
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(serverTLS)))
+		gwCreds = credentials.NewTLS(certs.LoopbackConfig())
+	} else {
+		slog.Warn("gRPC listener serves plaintext: no TLS certificate configured")
+	}
 
-	go func() {
-		<-quit
-		log.Println("shutting down gRPC server")
-		os.Exit(0)
-	}()
+	// Auth
+	authCfg := auth.Config{
+		JWKSFile:      cfg.AuthJWKSFile,
+		PublicKeyFile: cfg.AuthPublicKeyFile,
+		HMACSecret:    cfg.AuthHMACSecret,
+		Issuer:        cfg.AuthIssuer,
+		Audience:      cfg.AuthAudience,
+		AdminRole:     cfg.AuthAdminRole,
+	}
+	if authCfg.Enabled() {
+		verifier, err := auth.NewVerifier(authCfg)
+		if err != nil {
+			fatal("failed to init JWT verifier", err)
+		}
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(grpcserver.AuthUnaryInterceptor(verifier)),
+			grpc.ChainStreamInterceptor(grpcserver.AuthStreamInterceptor(verifier)),
+		)
+	} else {
+		slog.Warn("JWT authentication is disabled: no key source configured")
+	}
+	// Validation goes after auth, so unauthenticated callers learn nothing about request rules
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(grpcserver.ValidationUnaryInterceptor()),
+		grpc.ChainStreamInterceptor(grpcserver.ValidationStreamInterceptor()),
+	)
+	// Idempotency keys are claimed last, only by authenticated and valid calls
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(grpcserver.IdempotencyUnaryInterceptor(redisstorage.NewIdempotencyStore(rdb), cfg.IdempotencyTTL, cfg.IdempotencyLease)),
+	)
 
-	if err := grpcserver.Run(cfg.GRPCPort, srv); err != nil {
-		log.Fatalf("failed to run gRPC server: %v", err)
+	// Kafka Consumer (analysis results from process-service)
+	consumer, err := kafka.NewConsumer(cfg.KafkaBrokers, cfg.KafkaGroupID, cfg.KafkaResultsTopic, svc.HandleReviewResult)
+	if err != nil {
+		fatal("failed to init kafka consumer", err)
 	}
+	consumer.Start()
+	slog.Info("kafka consumer started", "topic", cfg.KafkaResultsTopic)
+
+	// Health: background dependency checks behind grpc.health.v1 and /healthz, /readyz
+	checker := health.New(cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+	checker.Add("redis", func(ctx context.Context) error { return rdb.Ping(ctx).Err() })
+	checker.Add("customer-service", client.Check)
+	checker.Add("kafka", producer.Check)
+	// Отзывы идут через outbox в Redis, поэтому ReviewService не зависит от Kafka напрямую
+	checker.Service(pb.UserProfileService_ServiceDesc.ServiceName, "redis", "customer-service")
+	checker.Service(reviewpb.ReviewService_ServiceDesc.ServiceName, "redis")
+	checker.Start()
+
+	// HTTP/JSON gateway (talks to our own gRPC listener)
+	gwConn, err := httpserver.Dial(cfg.GRPCPort, gwCreds)
+	if err != nil {
+		fatal("failed to dial gRPC server for HTTP gateway", err)
+	}
+	gw := httpserver.New(pb.NewUserProfileServiceClient(gwConn), reviewpb.NewReviewServiceClient(gwConn))
+
+	grpcServer := grpcserver.NewGRPCServer(serverOpts, srv, reviewSrv, checker)
+	gwServer := httpserver.NewHTTPServer(cfg.HTTPPort, gw)
+	adminServer := admin.NewHTTPServer(cfg.AdminPort, admin.New(checker))
+
+	app := lifecycle.New()
+	app.Go("gRPC server", func() error { return grpcserver.Run(cfg.GRPCPort, grpcServer) })
+	app.Go("HTTP gateway", func() error { return httpserver.Run(gwServer) })
+	app.Go("admin listener", func() error { return admin.Run(adminServer) })
+
+	// Dynamic settings are reloaded on SIGHUP and when the config file changes
+	watcher := config.NewWatcher(*configPath, cfg, func(d config.Dynamic) error {
+		// Сначала разбираем все, что может не пройти, и только потом применяем
+		limits, err := rateLimits(d)
+		if err != nil {
+			return err
+		}
+		if err := logging.SetLevel(d.LogLevel); err != nil {
+			return err
+		}
+		store.(redisstorage.TTLSetter).SetTTL(cacheTTL(d))
+		limiter.SetConfig(limits)
+		return nil
+	})
+	watcher.Start()
+
+	// Порядок важен: сначала перестаем принимать трафик, потом дожидаемся запросов,
+	// и только затем закрываем то, чем они пользуются
+	app.OnShutdown("config watcher", func(context.Context) error {
+		watcher.Close()
+		return nil
+	})
+	app.OnShutdown("readiness", func(context.Context) error {
+		checker.Shutdown()
+		return nil
+	})
+	// Shutdown закрывает и SSE-потоки, иначе один подписчик держал бы его до дедлайна
+	app.OnShutdownWithin("HTTP gateway", cfg.HTTPDrainTimeout, gwServer.Shutdown)
+	app.OnShutdownWithin("gRPC server", cfg.GRPCDrainTimeout, func(ctx context.Context) error {
+		// Подписки WatchReview бесконечны - ждем только unary-вызовы
+		reviewSrv.CloseStreams()
+		return grpcserver.Stop(ctx, grpcServer)
+	})
+	app.OnShutdown("gateway connection", func(context.Context) error { return gwConn.Close() })
+	app.OnShutdown("outbox relay", func(context.Context) error {
+		relay.Close()
+		return nil
+	})
+	app.OnShutdown("kafka producer", func(context.Context) error { return producer.Close() })
+	app.OnShutdown("kafka consumer", func(context.Context) error { return consumer.Close() })
+	app.OnShutdown("health checks", func(context.Context) error {
+		checker.Close()
+		return nil
+	})
+	app.OnShutdown("redis", func(context.Context) error {
+		stopBackground()
+		return rdb.Close()
+	})
+	app.OnShutdown("customer service connection", func(context.Context) error { return client.Close() })
+	app.OnShutdown("certificate reload", func(context.Context) error {
+		for _, certs := range certReloaders {
+			certs.Close()
+		}
+		return nil
+	})
+	app.OnShutdown("tracing", shutdownTracing)
+	app.OnShutdown("admin listener", adminServer.Shutdown)
+
+	if err := app.Wait(cfg.ShutdownTimeout, os.Interrupt, syscall.SIGTERM); err != nil {
+		fatal("gateway stopped", err)
+	}
+	slog.Info("gateway stopped")
+}
+
+// cacheTTL returns the settings cache lifetimes from d
+func cacheTTL(d config.Dynamic) redisstorage.CacheTTL {
+	return redisstorage.CacheTTL{Soft: d.CacheSoftTTL, Hard: d.CacheTTL, Stale: d.CacheStaleTTL}
+}
+
+// rateLimits parses the AnalyzeReview rate limits from d
+func rateLimits(d config.Dynamic) (ratelimit.Config, error) {
+	return ratelimit.ParseConfig(d.RateLimitUser, d.RateLimitSource, d.RateLimitGlobal, d.RateLimitSourceOverrides)
+}
+
+// printConfig writes the effective configuration with secrets redacted, then reports
+// validation errors; it returns the process exit code
+func printConfig(path string) int {
+	cfg, err := config.Read(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
 }
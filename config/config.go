@@ -1,23 +1,185 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
 	"github.com/ilyakaznacheev/cleanenv"
+	"gopkg.in/yaml.v3"
 )
 
-// Config holds application configuration loaded from environment variables
+// Config holds application configuration loaded from a YAML file and environment variables
 type Config struct {
-	GRPCPort            string   `env:"GRPC_PORT" env-default:":50052" yaml:"grpc_port"`
-	RedisAddr           string   `env:"REDIS_ADDR" env-default:"localhost:6379" yaml:"redis_addr"`
-	CustomerServiceAddr string   `env:"CUSTOMER_SERVICE_ADDR" env-default:"localhost:50051" yaml:"customer_service_addr"`
-	KafkaBrokers        []string `env:"KAFKA_BROKERS" env-default:"localhost:9092" yaml:"kafka_brokers"`
-	KafkaTopic          string   `env:"KAFKA_TOPIC" env-default:"reviews.raw" yaml:"kafka_topic"`
+	Static  `yaml:",inline"`
+	Dynamic `yaml:",inline"`
+}
+
+// Static settings are read once at startup; changing them requires a restart
+type Static struct {
+	LogFormat                string        `env:"LOG_FORMAT" env-default:"json" yaml:"log_format"`
+	ConfigReloadInterval     time.Duration `env:"CONFIG_RELOAD_INTERVAL" env-default:"10s" yaml:"config_reload_interval"`
+	GRPCPort                 string        `env:"GRPC_PORT" env-default:":50052" yaml:"grpc_port"`
+	HTTPPort                 string        `env:"HTTP_PORT" env-default:":8080" yaml:"http_port"`
+	AdminPort                string        `env:"ADMIN_PORT" env-default:":9090" yaml:"admin_port"`
+	GRPCTLSCertFile          string        `env:"GRPC_TLS_CERT_FILE" yaml:"grpc_tls_cert_file"`
+	GRPCTLSKeyFile           string        `env:"GRPC_TLS_KEY_FILE" yaml:"grpc_tls_key_file"`
+	GRPCTLSClientCAFile      string        `env:"GRPC_TLS_CLIENT_CA_FILE" yaml:"grpc_tls_client_ca_file"`
+	GRPCTLSClientAuth        string        `env:"GRPC_TLS_CLIENT_AUTH" env-default:"none" yaml:"grpc_tls_client_auth"`
+	TLSReloadInterval        time.Duration `env:"TLS_RELOAD_INTERVAL" env-default:"30s" yaml:"tls_reload_interval"`
+	HealthCheckInterval      time.Duration `env:"HEALTH_CHECK_INTERVAL" env-default:"5s" yaml:"health_check_interval"`
+	HealthCheckTimeout       time.Duration `env:"HEALTH_CHECK_TIMEOUT" env-default:"2s" yaml:"health_check_timeout"`
+	ShutdownTimeout          time.Duration `env:"SHUTDOWN_TIMEOUT" env-default:"30s" yaml:"shutdown_timeout"`
+	HTTPDrainTimeout         time.Duration `env:"HTTP_DRAIN_TIMEOUT" env-default:"5s" yaml:"http_drain_timeout"`
+	GRPCDrainTimeout         time.Duration `env:"GRPC_DRAIN_TIMEOUT" env-default:"20s" yaml:"grpc_drain_timeout"`
+	RedisAddr                string        `env:"REDIS_ADDR" env-default:"localhost:6379" yaml:"redis_addr"`
+	CacheL1Size              int           `env:"CACHE_L1_SIZE" env-default:"10000" yaml:"cache_l1_size"`
+	CacheL1TTL               time.Duration `env:"CACHE_L1_TTL" env-default:"5s" yaml:"cache_l1_ttl"`
+	CacheUpdateMode          string        `env:"CACHE_UPDATE_MODE" env-default:"invalidate" yaml:"cache_update_mode"`
+	IdempotencyTTL           time.Duration `env:"IDEMPOTENCY_TTL" env-default:"24h" yaml:"idempotency_ttl"`
+	IdempotencyLease         time.Duration `env:"IDEMPOTENCY_LEASE" env-default:"1m" yaml:"idempotency_lease"`
+	CustomerServiceAddr      string        `env:"CUSTOMER_SERVICE_ADDR" env-default:"localhost:50051" yaml:"customer_service_addr"`
+	CustomerReadTimeout      time.Duration `env:"CUSTOMER_READ_TIMEOUT" env-default:"1s" yaml:"customer_read_timeout"`
+	CustomerWriteTimeout     time.Duration `env:"CUSTOMER_WRITE_TIMEOUT" env-default:"3s" yaml:"customer_write_timeout"`
+	CustomerRetryAttempts    int           `env:"CUSTOMER_RETRY_ATTEMPTS" env-default:"3" yaml:"customer_retry_attempts"`
+	CustomerRetryBackoff     time.Duration `env:"CUSTOMER_RETRY_BACKOFF" env-default:"50ms" yaml:"customer_retry_backoff"`
+	CustomerRetryMaxBackoff  time.Duration `env:"CUSTOMER_RETRY_MAX_BACKOFF" env-default:"1s" yaml:"customer_retry_max_backoff"`
+	CustomerBreakerFailures  int           `env:"CUSTOMER_BREAKER_FAILURES" env-default:"5" yaml:"customer_breaker_failures"`
+	CustomerBreakerCooldown  time.Duration `env:"CUSTOMER_BREAKER_COOLDOWN" env-default:"10s" yaml:"customer_breaker_cooldown"`
+	CustomerKeepaliveTime    time.Duration `env:"CUSTOMER_KEEPALIVE_TIME" yaml:"customer_keepalive_time"`
+	CustomerKeepaliveTimeout time.Duration `env:"CUSTOMER_KEEPALIVE_TIMEOUT" env-default:"10s" yaml:"customer_keepalive_timeout"`
+	CustomerHealthCheck      bool          `env:"CUSTOMER_HEALTH_CHECK" env-default:"true" yaml:"customer_health_check"`
+	CustomerTLS              bool          `env:"CUSTOMER_TLS" yaml:"customer_tls"`
+	CustomerTLSCAFile        string        `env:"CUSTOMER_TLS_CA_FILE" yaml:"customer_tls_ca_file"`
+	CustomerTLSCertFile      string        `env:"CUSTOMER_TLS_CERT_FILE" yaml:"customer_tls_cert_file"`
+	CustomerTLSKeyFile       string        `env:"CUSTOMER_TLS_KEY_FILE" yaml:"customer_tls_key_file"`
+	CustomerTLSServerName    string        `env:"CUSTOMER_TLS_SERVER_NAME" yaml:"customer_tls_server_name"`
+	KafkaBrokers             []string      `env:"KAFKA_BROKERS" env-default:"localhost:9092" yaml:"kafka_brokers"`
+	KafkaTopic               string        `env:"KAFKA_TOPIC" env-default:"reviews.raw" yaml:"kafka_topic"`
+	KafkaResultsTopic        string        `env:"KAFKA_RESULTS_TOPIC" env-default:"reviews.analyzed" yaml:"kafka_results_topic"`
+	KafkaGroupID             string        `env:"KAFKA_GROUP_ID" env-default:"api-gateway" yaml:"kafka_group_id"`
+	AuthJWKSFile             string        `env:"AUTH_JWKS_FILE" yaml:"auth_jwks_file"`
+	AuthPublicKeyFile        string        `env:"AUTH_PUBLIC_KEY_FILE" yaml:"auth_public_key_file"`
+	AuthHMACSecret           string        `env:"AUTH_HMAC_SECRET" yaml:"auth_hmac_secret"`
+	AuthIssuer               string        `env:"AUTH_ISSUER" yaml:"auth_issuer"`
+	AuthAudience             string        `env:"AUTH_AUDIENCE" yaml:"auth_audience"`
+	AuthAdminRole            string        `env:"AUTH_ADMIN_ROLE" env-default:"admin" yaml:"auth_admin_role"`
+	TraceExporter            string        `env:"TRACE_EXPORTER" env-default:"none" yaml:"trace_exporter"`
+	TraceOTLPEndpoint        string        `env:"TRACE_OTLP_ENDPOINT" yaml:"trace_otlp_endpoint"`
+	TraceOTLPInsecure        bool          `env:"TRACE_OTLP_INSECURE" env-default:"true" yaml:"trace_otlp_insecure"`
+	TraceFile                string        `env:"TRACE_FILE" env-default:"traces.jsonl" yaml:"trace_file"`
+	TraceSampleRatio         float64       `env:"TRACE_SAMPLE_RATIO" env-default:"1" yaml:"trace_sample_ratio"`
+}
+
+// Dynamic settings can be changed on a running gateway, see Watcher
+type Dynamic struct {
+	LogLevel                 string            `env:"LOG_LEVEL" env-default:"info" yaml:"log_level"`
+	CacheSoftTTL             time.Duration     `env:"CACHE_SOFT_TTL" env-default:"1m" yaml:"cache_soft_ttl"`
+	CacheTTL                 time.Duration     `env:"CACHE_TTL" env-default:"10m" yaml:"cache_ttl"`
+	CacheStaleTTL            time.Duration     `env:"CACHE_STALE_TTL" env-default:"24h" yaml:"cache_stale_ttl"`
+	RateLimitUser            string            `env:"RATE_LIMIT_USER" env-default:"30/1m" yaml:"rate_limit_user"`
+	RateLimitSource          string            `env:"RATE_LIMIT_SOURCE" env-default:"600/1m" yaml:"rate_limit_source"`
+	RateLimitGlobal          string            `env:"RATE_LIMIT_GLOBAL" env-default:"3000/1m" yaml:"rate_limit_global"`
+	RateLimitSourceOverrides map[string]string `env:"RATE_LIMIT_SOURCE_OVERRIDES" yaml:"rate_limit_source_overrides"`
+}
+
+// EnvFile is the environment variable naming the config file when no path is given explicitly
+const EnvFile = "CONFIG_FILE"
+
+// Load reads configuration (see Read) and validates it
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
+// Read builds configuration from defaults, the YAML file at path (if any) and environment
+// variables, each layer overriding the previous one. Unknown keys in the file are an error
+func Read(path string) (*Config, error) {
 	cfg := &Config{}
 	if err := cleanenv.ReadEnv(cfg); err != nil {
 		return nil, err
 	}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var file map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	// Значения из файла применяем поверх умолчаний, но не поверх заданных переменных окружения
+	var errs []error
+	known := make(map[string]bool)
+	for _, f := range cfg.fields() {
+		known[f.key] = true
+		node, ok := file[f.key]
+		if !ok {
+			continue
+		}
+		if _, set := os.LookupEnv(f.env); set {
+			continue
+		}
+		if err := node.Decode(f.value.Addr().Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+	unknown := make([]string, 0)
+	for key := range file {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown key", key))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
 	return cfg, nil
 }
+
+// field is a single setting of Config, flattened out of Static and Dynamic
+type field struct {
+	key     string
+	env     string
+	dynamic bool
+	value   reflect.Value
+}
+
+// fields lists the settings of c in declaration order, static ones first
+func (c *Config) fields() []field {
+	var out []field
+	for _, part := range []struct {
+		v       reflect.Value
+		dynamic bool
+	}{
+		{reflect.ValueOf(&c.Static).Elem(), false},
+		{reflect.ValueOf(&c.Dynamic).Elem(), true},
+	} {
+		t := part.v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			out = append(out, field{
+				key:     t.Field(i).Tag.Get("yaml"),
+				env:     t.Field(i).Tag.Get("env"),
+				dynamic: part.dynamic,
+				value:   part.v.Field(i),
+			})
+		}
+	}
+	return out
+}
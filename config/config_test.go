@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRead_Layers(t *testing.T) {
+	path := writeFile(t, `
+redis_addr: redis:6379
+cache_ttl: 20m
+customer_health_check: false
+kafka_brokers: [kafka-1:9092, kafka-2:9092]
+`)
+	t.Setenv("CACHE_TTL", "30m")
+
+	cfg, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:6379", cfg.RedisAddr, "file overrides default")
+	assert.Equal(t, 30*time.Minute, cfg.CacheTTL, "env overrides file")
+	assert.False(t, cfg.CustomerHealthCheck, "explicit false in file is kept")
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.KafkaBrokers)
+	assert.Equal(t, time.Minute, cfg.CacheSoftTTL, "default when neither is set")
+}
+
+func TestRead_UnknownKeys(t *testing.T) {
+	path := writeFile(t, "redis_adr: redis:6379\ncache_ttl: soon\n")
+
+	_, err := Read(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redis_adr: unknown key")
+	assert.Contains(t, err.Error(), "cache_ttl:")
+}
+
+func TestValidate_ReportsAllErrors(t *testing.T) {
+	cfg, err := Read("")
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	cfg.GRPCPort = "50052"
+	cfg.KafkaBrokers = nil
+	cfg.CacheTTL = time.Second
+	cfg.CustomerServiceAddr = "customer"
+	cfg.TraceSampleRatio = 2
+	cfg.CacheUpdateMode = "write-back"
+	cfg.RateLimitUser = "100/500us"
+	cfg.RateLimitSourceOverrides = map[string]string{"partner": "lots"}
+
+	err = cfg.Validate()
+	require.Error(t, err)
+	for _, key := range []string{"grpc_port", "kafka_brokers", "cache_ttl", "cache_update_mode", "customer_service_addr", "trace_sample_ratio",
+		"rate_limit_user", "rate_limit_source_overrides.partner"} {
+		assert.Contains(t, err.Error(), key+":")
+	}
+	assert.Len(t, strings.Split(err.Error(), "\n"), 8)
+}
+
+func TestValidate_CustomerTLSServerName(t *testing.T) {
+	cfg, err := Read("")
+	require.NoError(t, err)
+	cfg.CustomerTLS = true
+
+	cfg.CustomerServiceAddr = "customer:50051"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.CustomerServiceAddr = "customer-1:50051, customer-2:50051"
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "customer_tls_server_name:")
+
+	cfg.CustomerTLSServerName = "customer.internal"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestPrint_RedactsSecretsAndRoundTrips(t *testing.T) {
+	cfg, err := Read("")
+	require.NoError(t, err)
+	cfg.AuthHMACSecret = "s3cret"
+	cfg.CacheTTL = 90 * time.Second
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Print(&buf))
+	assert.NotContains(t, buf.String(), "s3cret")
+	assert.Contains(t, buf.String(), "auth_hmac_secret: "+redacted)
+	assert.Contains(t, buf.String(), "cache_ttl: 1m30s")
+
+	printed, err := Read(writeFile(t, buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.CacheTTL, printed.CacheTTL)
+	assert.Equal(t, cfg.KafkaBrokers, printed.KafkaBrokers)
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := writeFile(t, "cache_ttl: 20m\nrate_limit_user: 10/1m\n")
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	var applied []Dynamic
+	w := NewWatcher(path, cfg, func(d Dynamic) error {
+		applied = append(applied, d)
+		return nil
+	})
+
+	// Статические настройки не применяются, динамические - применяются
+	require.NoError(t, os.WriteFile(path, []byte("cache_ttl: 30m\nrate_limit_user: 10/1m\ngrpc_port: \":50053\"\n"), 0o600))
+	require.NoError(t, w.Reload())
+	require.Len(t, applied, 1)
+	assert.Equal(t, 30*time.Minute, applied[0].CacheTTL)
+	assert.Equal(t, 30*time.Minute, w.Current().CacheTTL)
+	assert.Equal(t, ":50052", w.Current().GRPCPort)
+
+	// Invalid config is rejected as a whole
+	require.NoError(t, os.WriteFile(path, []byte("cache_ttl: 40m\ncache_soft_ttl: 1h\n"), 0o600))
+	assert.Error(t, w.Reload())
+	assert.Len(t, applied, 1)
+	assert.Equal(t, 30*time.Minute, w.Current().CacheTTL)
+	assert.False(t, w.fileChanged(), "rejected file is not re-read every tick")
+
+	// Nothing changed, nothing applied
+	require.NoError(t, os.WriteFile(path, []byte("cache_ttl: 30m\nrate_limit_user: 10/1m\n"), 0o600))
+	require.NoError(t, w.Reload())
+	assert.Len(t, applied, 1)
+}
+
+func TestWatcher_FileChange(t *testing.T) {
+	path := writeFile(t, "log_level: info\n")
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	cfg.ConfigReloadInterval = 10 * time.Millisecond
+
+	levels := make(chan string, 1)
+	w := NewWatcher(path, cfg, func(d Dynamic) error {
+		levels <- d.LogLevel
+		return nil
+	})
+	w.Start()
+	defer w.Close()
+
+	require.NoError(t, os.WriteFile(path, []byte("log_level: debug\n"), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	select {
+	case level := <-levels:
+		assert.Equal(t, "debug", level)
+	case <-time.After(2 * time.Second):
+		t.Fatal("file change was not picked up")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, err := Read("")
+	require.NoError(t, err)
+	b, err := Read("")
+	require.NoError(t, err)
+	b.AuthHMACSecret = "s3cret"
+	b.CacheTTL = time.Hour
+
+	static, dynamic := a.Diff(b)
+	assert.Equal(t, []string{"auth_hmac_secret:  -> " + redacted}, static)
+	assert.Equal(t, []string{"cache_ttl: 10m0s -> 1h0m0s"}, dynamic)
+}
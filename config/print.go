@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values in printed configuration
+const redacted = "REDACTED"
+
+// secrets are the yaml keys whose values are never printed
+var secrets = map[string]bool{
+	"auth_hmac_secret": true,
+}
+
+// Print writes the effective configuration to w as YAML, in the format Read accepts.
+// Secrets are redacted and durations are written as strings such as "30s"
+func (c *Config) Print(w io.Writer) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range c.fields() {
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(printable(f)); err != nil {
+			return err
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.key}, valueNode)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Diff lists the settings that differ between c and other as "key: old -> new",
+// split into static and dynamic ones. Secrets are redacted
+func (c *Config) Diff(other *Config) (static, dynamic []string) {
+	next := other.fields()
+	for i, f := range c.fields() {
+		if reflect.DeepEqual(f.value.Interface(), next[i].value.Interface()) {
+			continue
+		}
+		change := fmt.Sprintf("%s: %v -> %v", f.key, printable(f), printable(next[i]))
+		if f.dynamic {
+			dynamic = append(dynamic, change)
+		} else {
+			static = append(static, change)
+		}
+	}
+	return static, dynamic
+}
+
+// printable returns the value of f as it is shown to people
+func printable(f field) any {
+	value := f.value.Interface()
+	switch v := value.(type) {
+	case string:
+		if secrets[f.key] && v != "" {
+			return redacted
+		}
+	case time.Duration:
+		return v.String()
+	}
+	return value
+}
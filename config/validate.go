@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/internal/ratelimit/limit"
+)
+
+// Validate checks the configuration and reports every problem at once
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("log_format", c.LogFormat, "json", "text")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		v.fail("log_level", "unknown level %q", c.LogLevel)
+	}
+
+	v.listenAddr("grpc_port", c.GRPCPort)
+	v.listenAddr("http_port", c.HTTPPort)
+	v.listenAddr("admin_port", c.AdminPort)
+	if (c.GRPCTLSCertFile == "") != (c.GRPCTLSKeyFile == "") {
+		v.fail("grpc_tls_cert_file", "must be set together with grpc_tls_key_file")
+	}
+	v.oneOf("grpc_tls_client_auth", c.GRPCTLSClientAuth, "none", "verify-if-given", "require")
+	if c.GRPCTLSClientAuth != "none" && c.GRPCTLSClientCAFile == "" {
+		v.fail("grpc_tls_client_ca_file", "is required when grpc_tls_client_auth is %q", c.GRPCTLSClientAuth)
+	}
+	if c.GRPCTLSClientAuth != "none" && c.GRPCTLSCertFile == "" {
+		v.fail("grpc_tls_client_auth", "needs grpc_tls_cert_file: client certificates are only verified over TLS")
+	}
+	v.nonNegative("tls_reload_interval", c.TLSReloadInterval)
+
+	v.positive("health_check_interval", c.HealthCheckInterval)
+	v.positive("health_check_timeout", c.HealthCheckTimeout)
+	v.positive("shutdown_timeout", c.ShutdownTimeout)
+	v.positive("http_drain_timeout", c.HTTPDrainTimeout)
+	v.positive("grpc_drain_timeout", c.GRPCDrainTimeout)
+	// Оба сервера дренируются по очереди, и после них еще нужно время на остальные шаги
+	if c.HTTPDrainTimeout+c.GRPCDrainTimeout >= c.ShutdownTimeout {
+		v.fail("grpc_drain_timeout", "plus http_drain_timeout (%s) must be less than shutdown_timeout (%s)", c.HTTPDrainTimeout, c.ShutdownTimeout)
+	}
+
+	v.hostPort("redis_addr", c.RedisAddr)
+	v.positive("cache_soft_ttl", c.CacheSoftTTL)
+	if c.CacheTTL < c.CacheSoftTTL {
+		v.fail("cache_ttl", "must be at least cache_soft_ttl (%s)", c.CacheSoftTTL)
+	}
+	if c.CacheStaleTTL < c.CacheTTL {
+		v.fail("cache_stale_ttl", "must be at least cache_ttl (%s)", c.CacheTTL)
+	}
+	if c.CacheL1Size < 0 {
+		v.fail("cache_l1_size", "must not be negative")
+	}
+	if c.CacheL1Size > 0 {
+		v.positive("cache_l1_ttl", c.CacheL1TTL)
+	}
+	v.oneOf("cache_update_mode", c.CacheUpdateMode, "invalidate", "write-through")
+	v.positive("idempotency_ttl", c.IdempotencyTTL)
+	v.positive("idempotency_lease", c.IdempotencyLease)
+
+	v.customerAddr("customer_service_addr", c.CustomerServiceAddr)
+	v.positive("customer_read_timeout", c.CustomerReadTimeout)
+	v.positive("customer_write_timeout", c.CustomerWriteTimeout)
+	if c.CustomerRetryAttempts < 1 {
+		v.fail("customer_retry_attempts", "must be at least 1")
+	}
+	v.nonNegative("customer_retry_backoff", c.CustomerRetryBackoff)
+	if c.CustomerRetryMaxBackoff < c.CustomerRetryBackoff {
+		v.fail("customer_retry_max_backoff", "must be at least customer_retry_backoff (%s)", c.CustomerRetryBackoff)
+	}
+	if c.CustomerBreakerFailures < 0 {
+		v.fail("customer_breaker_failures", "must not be negative")
+	}
+	if c.CustomerBreakerFailures > 0 {
+		v.positive("customer_breaker_cooldown", c.CustomerBreakerCooldown)
+	}
+	v.nonNegative("customer_keepalive_time", c.CustomerKeepaliveTime)
+	v.nonNegative("customer_keepalive_timeout", c.CustomerKeepaliveTimeout)
+	if (c.CustomerTLSCertFile == "") != (c.CustomerTLSKeyFile == "") {
+		v.fail("customer_tls_cert_file", "must be set together with customer_tls_key_file")
+	}
+	if !c.CustomerTLS && (c.CustomerTLSCAFile != "" || c.CustomerTLSCertFile != "") {
+		v.fail("customer_tls", "is off, but customer TLS files are set")
+	}
+	// У статического списка реплик нет общего имени хоста - сертификат проверить не с чем
+	if c.CustomerTLS && c.CustomerTLSServerName == "" && len(customerReplicas(c.CustomerServiceAddr)) > 1 {
+		v.fail("customer_tls_server_name", "is required when customer_tls is on and customer_service_addr lists several replicas")
+	}
+
+	if len(c.KafkaBrokers) == 0 {
+		v.fail("kafka_brokers", "must list at least one broker")
+	}
+	for _, broker := range c.KafkaBrokers {
+		v.hostPort("kafka_brokers", broker)
+	}
+	v.nonEmpty("kafka_topic", c.KafkaTopic)
+	v.nonEmpty("kafka_results_topic", c.KafkaResultsTopic)
+	v.nonEmpty("kafka_group_id", c.KafkaGroupID)
+
+	v.oneOf("trace_exporter", c.TraceExporter, "none", "otlp", "stdout", "file")
+	if c.TraceExporter == "file" {
+		v.nonEmpty("trace_file", c.TraceFile)
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		v.fail("trace_sample_ratio", "must be between 0 and 1, got %v", c.TraceSampleRatio)
+	}
+
+	v.rateLimit("rate_limit_user", c.RateLimitUser)
+	v.rateLimit("rate_limit_source", c.RateLimitSource)
+	v.rateLimit("rate_limit_global", c.RateLimitGlobal)
+	sources := make([]string, 0, len(c.RateLimitSourceOverrides))
+	for name := range c.RateLimitSourceOverrides {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+	for _, name := range sources {
+		v.rateLimit("rate_limit_source_overrides."+name, c.RateLimitSourceOverrides[name])
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects validation errors
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(key, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) nonEmpty(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(key, "must not be empty")
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.fail(key, "must be positive, got %s", d)
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.fail(key, "must not be negative, got %s", d)
+	}
+}
+
+// rateLimit accepts what limit.Parse does, e.g. "100/1m"
+func (v *validator) rateLimit(key, spec string) {
+	if _, err := limit.Parse(spec); err != nil {
+		v.fail(key, "%v", err)
+	}
+}
+
+// listenAddr accepts [host]:port, e.g. ":8080"
+func (v *validator) listenAddr(key, addr string) {
+	if _, port, err := net.SplitHostPort(addr); err != nil || !validPort(port) {
+		v.fail(key, "%q is not a [host]:port listen address", addr)
+	}
+}
+
+// hostPort accepts host:port with a non-empty host
+func (v *validator) hostPort(key, addr string) {
+	if host, port, err := net.SplitHostPort(addr); err != nil || host == "" || !validPort(port) {
+		v.fail(key, "%q is not a host:port address", addr)
+	}
+}
+
+// customerAddr accepts a gRPC target with a scheme or a comma-separated list of host:port
+func (v *validator) customerAddr(key, addr string) {
+	if strings.Contains(addr, "://") {
+		return
+	}
+	replicas := customerReplicas(addr)
+	for _, a := range replicas {
+		v.hostPort(key, a)
+	}
+	if len(replicas) == 0 {
+		v.fail(key, "must not be empty")
+	}
+}
+
+// customerReplicas splits a comma-separated list of customer service replicas;
+// a target with a scheme is resolved by gRPC and yields nil
+func customerReplicas(addr string) []string {
+	if strings.Contains(addr, "://") {
+		return nil
+	}
+	var replicas []string
+	for _, a := range strings.Split(addr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			replicas = append(replicas, a)
+		}
+	}
+	return replicas
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
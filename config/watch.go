@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Watcher re-reads configuration on SIGHUP and, when a file is used, whenever the file
+// changes. Valid dynamic settings are handed to apply; static ones only take effect after a restart
+type Watcher struct {
+	path     string
+	interval time.Duration
+	apply    func(Dynamic) error
+
+	mu      sync.Mutex
+	current *Config
+	stamp   stamp
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// stamp identifies a version of the config file
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher creates a watcher for the configuration loaded from path (empty when only the
+// environment is used). current is the configuration the gateway is running with
+func NewWatcher(path string, current *Config, apply func(Dynamic) error) *Watcher {
+	w := &Watcher{path: path, interval: current.ConfigReloadInterval, apply: apply, current: current}
+	w.stamp, _ = w.stat()
+	return w
+}
+
+// Start listens for SIGHUP and polls the file every ConfigReloadInterval until Close
+func (w *Watcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer signal.Stop(hup)
+
+		var tick <-chan time.Time
+		if w.path != "" && w.interval > 0 {
+			ticker := time.NewTicker(w.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				slog.Info("reloading config", "reason", "SIGHUP")
+			case <-tick:
+				if !w.fileChanged() {
+					continue
+				}
+				slog.Info("reloading config", "reason", "file changed", "path", w.path)
+			}
+			if err := w.Reload(); err != nil {
+				slog.Error("config reload failed, keeping current settings", "error", err)
+			}
+		}
+	}()
+}
+
+// Close stops watching
+func (w *Watcher) Close() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// Reload reads and validates the configuration and applies changed dynamic settings.
+// On any error the running settings stay as they were
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Запоминаем версию файла до проверки: невалидный файл иначе перечитывался бы
+	// и попадал в лог каждый тик, пока его не исправят
+	w.stamp, _ = w.stat()
+	next, err := Load(w.path)
+	if err != nil {
+		return err
+	}
+
+	static, dynamic := w.current.Diff(next)
+	if len(static) > 0 {
+		slog.Warn("static settings changed, restart to apply them", "changes", static)
+	}
+	if len(dynamic) == 0 {
+		slog.Info("config reloaded, no dynamic settings changed")
+		return nil
+	}
+	if err := w.apply(next.Dynamic); err != nil {
+		return fmt.Errorf("apply dynamic settings: %w", err)
+	}
+	w.current = &Config{Static: w.current.Static, Dynamic: next.Dynamic}
+	slog.Info("config reloaded", "changes", dynamic)
+	return nil
+}
+
+// Current returns the configuration the gateway is running with
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// fileChanged reports whether the file differs from the version last reloaded
+func (w *Watcher) fileChanged() bool {
+	s, err := w.stat()
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return s != w.stamp
+}
+
+func (w *Watcher) stat() (stamp, error) {
+	if w.path == "" {
+		return stamp{}, nil
+	}
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return stamp{}, err
+	}
+	return stamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newClaims(sub string, roles ...string) *Claims {
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sub,
+			Issuer:    "https://issuer.test",
+			Audience:  jwt.ClaimStrings{"api-gateway"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: roles,
+	}
+}
+
+func TestVerifier_HMAC(t *testing.T) {
+	v, err := NewVerifier(Config{HMACSecret: "secret", Issuer: "https://issuer.test", Audience: "api-gateway", AdminRole: "admin"})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims("user1", "admin")).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	claims, err := v.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+	assert.True(t, claims.IsAdmin())
+}
+
+func TestVerifier_RejectsWrongAudience(t *testing.T) {
+	v, err := NewVerifier(Config{HMACSecret: "secret", Audience: "api-gateway"})
+	require.NoError(t, err)
+
+	claims := newClaims("user1")
+	claims.Audience = jwt.ClaimStrings{"someone-else"}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+
+	_, err = v.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestVerifier_RejectsExpired(t *testing.T) {
+	v, err := NewVerifier(Config{HMACSecret: "secret"})
+	require.NoError(t, err)
+
+	claims := newClaims("user1")
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+
+	_, err = v.Verify(token)
+	assert.Error(t, err)
+}
+
+func TestVerifier_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	v, err := NewVerifier(Config{JWKSFile: path})
+	require.NoError(t, err)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, newClaims("user1"))
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(key)
+	require.NoError(t, err)
+
+	claims, err := v.Verify(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", claims.Subject)
+
+	// HMAC tokens must not be accepted when only public keys are configured
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims("user1")).SignedString([]byte("whatever"))
+	_, err = v.Verify(forged)
+	assert.Error(t, err)
+}
+
+func TestCheckSubject(t *testing.T) {
+	owner := NewContext(context.Background(), newClaims("user1"))
+	assert.NoError(t, CheckSubject(owner, "user1"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(CheckSubject(owner, "user2")))
+
+	admin := NewContext(context.Background(), &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "ops"}, admin: true})
+	assert.NoError(t, CheckSubject(admin, "user2"))
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	rsaMethods  = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecMethods   = []string{"ES256", "ES384", "ES512"}
+	hmacMethods = []string{"HS256", "HS384", "HS512"}
+)
+
+// keySource resolves the verification key for a token
+type keySource interface {
+	key(token *jwt.Token) (interface{}, error)
+	methods() []string
+}
+
+// hmacKey verifies tokens signed with a shared secret
+type hmacKey []byte
+
+func (k hmacKey) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return []byte(k), nil
+}
+
+func (k hmacKey) methods() []string {
+	return hmacMethods
+}
+
+// publicKeys verifies tokens against RSA/EC public keys, selected by kid when there are several
+type publicKeys struct {
+	byID map[string]interface{}
+	// single is used for tokens without kid when exactly one key is known
+	single interface{}
+}
+
+func (k *publicKeys) key(token *jwt.Token) (interface{}, error) {
+	var key interface{}
+	if kid, _ := token.Header["kid"].(string); kid != "" {
+		key = k.byID[kid]
+		if key == nil {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	} else if k.single != nil {
+		key = k.single
+	} else {
+		return nil, errors.New("token has no kid")
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return key, nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("signing method %s does not match key type", token.Method.Alg())
+}
+
+func (k *publicKeys) methods() []string {
+	return append(append([]string{}, rsaMethods...), ecMethods...)
+}
+
+// loadPublicKey reads a single PEM-encoded RSA or EC public key
+func loadPublicKey(path string) (*publicKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	var key interface{}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	} else if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("%s: unsupported public key type %T", path, key)
+	}
+	return &publicKeys{byID: map[string]interface{}{}, single: key}, nil
+}
+
+// jwk is the subset of RFC 7517 fields needed for RSA and EC signature keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS reads a JSON Web Key Set from disk
+func loadJWKS(path string) (*publicKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := &publicKeys{byID: make(map[string]interface{})}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %d: %w", path, i, err)
+		}
+		keys.byID[k.Kid] = key
+	}
+	if len(keys.byID) == 0 {
+		return nil, fmt.Errorf("%s: no signature keys", path)
+	}
+	if len(keys.byID) == 1 {
+		for _, key := range keys.byID {
+			keys.single = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,162 @@
+package customerclient
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/metrics"
+)
+
+// BreakerState is the state of the circuit breaker
+type BreakerState int
+
+// Breaker states; the values are exported as the customer_client_breaker_state gauge
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// errBreakerOpen is returned without calling customer service while the breaker is open
+var errBreakerOpen = status.Error(codes.Unavailable, "customer service circuit breaker is open")
+
+// breaker opens after threshold consecutive failures and rejects calls for cooldown.
+// After cooldown a single probe call is let through: success closes the breaker, failure opens it again.
+// Every state change starts a new generation; outcomes of calls allowed in an earlier generation
+// are ignored, so a straggler cannot close an open breaker or take the place of the probe
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// generation is bumped by setState
+	generation uint64
+}
+
+// newBreaker creates a breaker; threshold <= 0 disables it
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	metrics.CustomerBreakerState.Set(float64(BreakerClosed))
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a call may go to customer service and returns the generation
+// it was allowed in. Every allowed call must be followed by done or skip with that generation
+func (b *breaker) allow() (uint64, bool) {
+	if b.threshold <= 0 {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return 0, false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return b.generation, true
+	case BreakerHalfOpen:
+		// Пока пробный вызов не вернулся, остальные получают отказ
+		if b.probing {
+			return 0, false
+		}
+		b.probing = true
+		return b.generation, true
+	default:
+		return b.generation, true
+	}
+}
+
+// done records the outcome of a call allowed in generation
+func (b *breaker) done(generation uint64, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Вызов пропущен в другом состоянии - его исход о текущем ничего не говорит
+	if generation != b.generation {
+		return
+	}
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+	if !isFailure(err) {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// skip ends a call allowed in generation without recording its outcome, e.g. when the
+// caller gave up on it. A skipped probe lets the next call probe instead
+func (b *breaker) skip(generation uint64) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// State returns the current breaker state
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState switches the state; b.mu must be held
+func (b *breaker) setState(s BreakerState) {
+	if s == BreakerOpen {
+		slog.Warn("customer service circuit breaker opened", "from", b.state.String(), "failures", b.failures, "cooldown", b.cooldown)
+	} else {
+		slog.Info("customer service circuit breaker state changed", "from", b.state.String(), "to", s.String())
+	}
+	b.state = s
+	b.generation++
+	metrics.CustomerBreakerState.Set(float64(s))
+	metrics.CustomerBreakerTransitions.WithLabelValues(s.String()).Inc()
+}
+
+// isFailure reports whether err says customer service is unhealthy. Errors about the request itself
+// (bad argument, not found and so on) mean the service answered and do not count
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
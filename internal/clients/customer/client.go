@@ -2,45 +2,224 @@ package customerclient
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/logging"
+	"api-gateway/internal/metrics"
 
 	customer "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
 )
 
+// Config sets deadlines, retries and the circuit breaker for calls to customer service
+type Config struct {
+	// ReadTimeout bounds each attempt of an idempotent call, WriteTimeout any other call
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// RetryAttempts is the maximum number of attempts of an idempotent call, including the first one
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// BreakerFailures consecutive failures open the breaker for BreakerCooldown; 0 disables it
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// KeepaliveTime is the idle time before pinging a replica, KeepaliveTimeout how long to wait for the ack;
+	// 0 disables keepalive pings. Pings are sent on idle connections too, which a grpc-go server rejects
+	// with GOAWAY "too_many_pings" unless its keepalive.EnforcementPolicy has MinTime <= KeepaliveTime
+	// and PermitWithoutStream set (the default policy allows one ping per 5 minutes with an active stream only)
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	// TLS secures the connection; nil dials in plaintext
+	TLS *tls.Config
+	// HealthCheck takes replicas out of rotation while their grpc.health.v1 status is not SERVING
+	HealthCheck bool
+}
+
 // Client is a wrapper around the generated gRPC client
 type Client struct {
-	client customer.UserProfileServiceClient
-	conn   *grpc.ClientConn
+	client  customer.UserProfileServiceClient
+	conn    *grpc.ClientConn
+	cfg     Config
+	breaker *breaker
 }
 
-// New connects to customer service and returns the wrapped client
-func New(addr string) (*Client, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+// New creates a client for customer service at addr (see target for the accepted forms).
+// It does not wait for a connection: replicas are dialed in the background and redialed
+// with backoff when they go away, so a slow-starting customer service does not stop the gateway
+func New(addr string, cfg Config) (*Client, error) {
+	tgt, opts, err := target(addr)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if cfg.TLS != nil {
+		creds = credentials.NewTLS(cfg.TLS)
+	}
+	opts = append(opts,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(serviceConfig(cfg.HealthCheck)),
+		// Spans for downstream calls; trace context goes to customer service in metadata
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(forwardRequestID))
+	if cfg.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepaliveParams(cfg)))
+	}
+	conn, err := grpc.NewClient(tgt, opts...)
 	if err != nil {
 		return nil, err
 	}
+	// Начинаем подключаться сразу, чтобы первый запрос не ждал установки соединения
+	conn.Connect()
 	c := customer.NewUserProfileServiceClient(conn)
-	return &Client{client: c, conn: conn}, nil
+	return &Client{client: c, conn: conn, cfg: cfg, breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)}, nil
 }
 
-// GetSettings calls downstream customer service GetUserSettings
-func (c *Client) GetSettings(ctx context.Context, req *customer.GetUserSettingsRequest) (*customer.GetUserSettingsResponse, error) {
-	return c.client.GetUserSettings(ctx, req)
+// GetSettings calls downstream customer service GetUserSettings; it is retried on transient errors
+func (c *Client) GetSettings(ctx context.Context, req *customer.GetUserSettingsRequest) (resp *customer.GetUserSettingsResponse, err error) {
+	err = c.call(ctx, "GetSettings", true, func(ctx context.Context) error {
+		var err error
+		resp, err = c.client.GetUserSettings(ctx, req)
+		return err
+	})
+	return resp, err
 }
 
 // UpdateSettings calls downstream customer service UpdateUserSettings
-func (c *Client) UpdateSettings(ctx context.Context, req *customer.UpdateUserSettingsRequest) (*customer.UpdateUserSettingsResponse, error) {
-	return c.client.UpdateUserSettings(ctx, req)
+func (c *Client) UpdateSettings(ctx context.Context, req *customer.UpdateUserSettingsRequest) (resp *customer.UpdateUserSettingsResponse, err error) {
+	err = c.call(ctx, "UpdateSettings", false, func(ctx context.Context) error {
+		var err error
+		resp, err = c.client.UpdateUserSettings(ctx, req)
+		return err
+	})
+	return resp, err
 }
 
 // Forwards CreateUserProfile
-func (c *Client) CreateUserProfile(ctx context.Context, req *customer.CreateUserProfileRequest) (*customer.CreateUserProfileResponse, error) {
-	return c.client.CreateUserProfile(ctx, req)
+func (c *Client) CreateUserProfile(ctx context.Context, req *customer.CreateUserProfileRequest) (resp *customer.CreateUserProfileResponse, err error) {
+	err = c.call(ctx, "CreateUserProfile", false, func(ctx context.Context) error {
+		var err error
+		resp, err = c.client.CreateUserProfile(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// call runs fn through the circuit breaker with the method's deadline. Idempotent calls are
+// retried with exponential backoff on retryable codes while the caller's context allows
+func (c *Client) call(ctx context.Context, method string, idempotent bool, fn func(ctx context.Context) error) (err error) {
+	defer observe(method, time.Now(), &err)
+
+	timeout, attempts := c.cfg.WriteTimeout, 1
+	if idempotent {
+		timeout = c.cfg.ReadTimeout
+		attempts = max(c.cfg.RetryAttempts, 1)
+	}
+	for attempt := 1; ; attempt++ {
+		generation, ok := c.breaker.allow()
+		if !ok {
+			metrics.CustomerBreakerRejected.WithLabelValues(method).Inc()
+			return errBreakerOpen
+		}
+		err = attemptWithTimeout(ctx, timeout, fn)
+		if ctx.Err() != nil {
+			// Вызывающий ушел сам: отмена или его дедлайн не говорят о здоровье сервиса
+			c.breaker.skip(generation)
+		} else {
+			c.breaker.done(generation, err)
+		}
+		if err == nil || attempt >= attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := backoff(c.cfg.RetryBackoff, c.cfg.RetryMaxBackoff, attempt)
+		slog.DebugContext(ctx, "retrying customer service call", "client_method", method, "attempt", attempt, "delay", delay, "error", err)
+		metrics.CustomerRetries.WithLabelValues(method).Inc()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func attemptWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// retryable reports whether a failed attempt may succeed if repeated
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the next attempt: base doubled per attempt up to maxDelay,
+// of which the upper half is random so that replicas do not retry in lockstep
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << (attempt - 1)
+	if d <= 0 || (maxDelay > 0 && d > maxDelay) {
+		d = maxDelay
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// forwardRequestID passes the caller's request ID to customer service
+func forwardRequestID(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := logging.RequestID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, logging.RequestIDHeader, id)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// observe records latency and, on failure, the status code of a downstream call
+func observe(method string, start time.Time, err *error) {
+	metrics.CustomerDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		metrics.CustomerErrors.WithLabelValues(method, status.Code(*err).String()).Inc()
+	}
+}
+
+// Check reports whether the connection to customer service is usable and the breaker is not open.
+// An idle connection is asked to reconnect and counts as healthy
+func (c *Client) Check(ctx context.Context) error {
+	if c.breaker.State() == BreakerOpen {
+		return errBreakerOpen
+	}
+	switch state := c.conn.GetState(); state {
+	case connectivity.Ready:
+		return nil
+	case connectivity.Idle:
+		c.conn.Connect()
+		return nil
+	default:
+		return fmt.Errorf("customer service connection is %s", state)
+	}
 }
 
 // Close is a noop for now, but provided for symmetry if conn handling is changed
@@ -0,0 +1,216 @@
+package customerclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	customer "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// fakeCustomer fails the first failures calls of each method with code
+type fakeCustomer struct {
+	customer.UserProfileServiceClient
+	code     codes.Code
+	failures int
+	calls    int
+}
+
+func (f *fakeCustomer) result() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return status.Error(f.code, "fake failure")
+	}
+	return nil
+}
+
+func (f *fakeCustomer) GetUserSettings(ctx context.Context, in *customer.GetUserSettingsRequest, opts ...grpc.CallOption) (*customer.GetUserSettingsResponse, error) {
+	if err := f.result(); err != nil {
+		return nil, err
+	}
+	return &customer.GetUserSettingsResponse{}, nil
+}
+
+func (f *fakeCustomer) UpdateUserSettings(ctx context.Context, in *customer.UpdateUserSettingsRequest, opts ...grpc.CallOption) (*customer.UpdateUserSettingsResponse, error) {
+	if err := f.result(); err != nil {
+		return nil, err
+	}
+	return &customer.UpdateUserSettingsResponse{}, nil
+}
+
+func newTestClient(fake *fakeCustomer, cfg Config) *Client {
+	return &Client{client: fake, cfg: cfg, breaker: newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)}
+}
+
+func TestGetSettings_RetriesTransientErrors(t *testing.T) {
+	fake := &fakeCustomer{code: codes.Unavailable, failures: 2}
+	c := newTestClient(fake, Config{RetryAttempts: 3, RetryBackoff: time.Millisecond})
+
+	resp, err := c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, 3, fake.calls)
+}
+
+func TestGetSettings_DoesNotRetryPermanentErrors(t *testing.T) {
+	fake := &fakeCustomer{code: codes.InvalidArgument, failures: 1}
+	c := newTestClient(fake, Config{RetryAttempts: 3, RetryBackoff: time.Millisecond})
+
+	_, err := c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 1, fake.calls)
+}
+
+func TestUpdateSettings_NotRetried(t *testing.T) {
+	fake := &fakeCustomer{code: codes.Unavailable, failures: 1}
+	c := newTestClient(fake, Config{RetryAttempts: 3, RetryBackoff: time.Millisecond})
+
+	_, err := c.UpdateSettings(context.Background(), &customer.UpdateUserSettingsRequest{UserId: "u1"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, fake.calls)
+}
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	fake := &fakeCustomer{code: codes.Unavailable, failures: 2}
+	c := newTestClient(fake, Config{RetryAttempts: 1, BreakerFailures: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+		require.Error(t, err)
+	}
+	assert.Equal(t, BreakerOpen, c.breaker.State())
+	assert.Error(t, c.Check(context.Background()))
+
+	// Открытый предохранитель отвечает сразу, не вызывая сервис
+	_, err := c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 2, fake.calls)
+
+	// После паузы пробный вызов проходит и закрывает предохранитель
+	now = now.Add(time.Minute)
+	_, err = c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, c.breaker.State())
+	assert.Equal(t, 3, fake.calls)
+}
+
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	gen, ok := b.allow()
+	require.True(t, ok)
+	b.done(gen, status.Error(codes.Unavailable, "down"))
+	assert.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(time.Minute)
+	probe, ok := b.allow()
+	require.True(t, ok)
+	_, ok = b.allow()
+	assert.False(t, ok, "only one probe at a time")
+	b.done(probe, status.Error(codes.DeadlineExceeded, "slow"))
+	assert.Equal(t, BreakerOpen, b.State())
+	_, ok = b.allow()
+	assert.False(t, ok)
+}
+
+func TestBreaker_IgnoresStragglers(t *testing.T) {
+	b := newBreaker(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	// Два вызова ушли, пока предохранитель был закрыт; первый его открыл
+	failed, _ := b.allow()
+	straggler, _ := b.allow()
+	b.done(failed, status.Error(codes.Unavailable, "down"))
+	require.Equal(t, BreakerOpen, b.State())
+
+	// Успех запоздавшего вызова не закрывает открытый предохранитель
+	b.done(straggler, nil)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// И не снимает пробу: решает только она
+	now = now.Add(time.Minute)
+	probe, ok := b.allow()
+	require.True(t, ok)
+	b.done(straggler, nil)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	_, ok = b.allow()
+	assert.False(t, ok)
+
+	b.done(probe, nil)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestBreaker_CallerCancellationNotCounted(t *testing.T) {
+	fake := &fakeCustomer{code: codes.DeadlineExceeded, failures: 10}
+	c := newTestClient(fake, Config{RetryAttempts: 1, BreakerFailures: 1, BreakerCooldown: time.Minute})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.GetSettings(ctx, &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.Error(t, err)
+	assert.Equal(t, BreakerClosed, c.breaker.State())
+
+	// Отмененная проба освобождает место для следующей
+	_, err = c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.Error(t, err)
+	require.Equal(t, BreakerOpen, c.breaker.State())
+	now = now.Add(time.Minute)
+	_, err = c.GetSettings(ctx, &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.Error(t, err)
+	assert.Equal(t, BreakerHalfOpen, c.breaker.State())
+	fake.failures = 0
+	_, err = c.GetSettings(context.Background(), &customer.GetUserSettingsRequest{UserId: "u1"})
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, c.breaker.State())
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoff(10*time.Millisecond, 100*time.Millisecond, attempt)
+		want := min(10*time.Millisecond<<(attempt-1), 100*time.Millisecond)
+		assert.GreaterOrEqual(t, d, want/2)
+		assert.LessOrEqual(t, d, want)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tgt, opts, err := target("dns:///customer:50051")
+	require.NoError(t, err)
+	assert.Equal(t, "dns:///customer:50051", tgt)
+	assert.Empty(t, opts)
+
+	tgt, opts, err = target("customer:50051")
+	require.NoError(t, err)
+	assert.Equal(t, "dns:///customer:50051", tgt)
+	assert.Empty(t, opts)
+
+	tgt, opts, err = target("a:50051, b:50051")
+	require.NoError(t, err)
+	assert.Equal(t, poolScheme+":///customer-service", tgt)
+	assert.Len(t, opts, 1)
+
+	_, _, err = target(" , ")
+	assert.Error(t, err)
+}
+
+func TestNew_DoesNotWaitForConnection(t *testing.T) {
+	start := time.Now()
+	// Порт 1 никто не слушает - клиент должен создаться сразу и переподключаться в фоне
+	c, err := New("127.0.0.1:1,127.0.0.1:2", Config{KeepaliveTime: time.Minute, KeepaliveTimeout: time.Second, HealthCheck: true})
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Less(t, time.Since(start), time.Second)
+}
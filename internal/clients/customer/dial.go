@@ -0,0 +1,60 @@
+package customerclient
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/health" // client-side health checking for healthCheckConfig
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// poolScheme is the resolver scheme of a static list of customer service replicas
+const poolScheme = "customer-pool"
+
+// target turns addr into a gRPC target. addr is either a target with a scheme (dns:///host:port),
+// a comma-separated list of replicas resolved statically, or a single host:port resolved through DNS
+func target(addr string) (string, []grpc.DialOption, error) {
+	if strings.Contains(addr, "://") {
+		return addr, nil, nil
+	}
+
+	var addrs []resolver.Address
+	for _, a := range strings.Split(addr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, resolver.Address{Addr: a})
+		}
+	}
+	switch len(addrs) {
+	case 0:
+		return "", nil, fmt.Errorf("customer service address is empty")
+	case 1:
+		// У одного хоста может быть несколько A-записей - round_robin разнесет нагрузку и по ним
+		return "dns:///" + addrs[0].Addr, nil, nil
+	}
+	r := manual.NewBuilderWithScheme(poolScheme)
+	r.InitialState(resolver.State{Addresses: addrs})
+	return poolScheme + ":///customer-service", []grpc.DialOption{grpc.WithResolvers(r)}, nil
+}
+
+// serviceConfig spreads calls over all resolved replicas. With healthCheck set, replicas whose
+// grpc.health.v1 status is not SERVING are taken out of rotation until they recover;
+// replicas that do not implement the health service are treated as healthy
+func serviceConfig(healthCheck bool) string {
+	if !healthCheck {
+		return `{"loadBalancingConfig":[{"round_robin":{}}]}`
+	}
+	return `{"loadBalancingConfig":[{"round_robin":{}}],"healthCheckConfig":{"serviceName":""}}`
+}
+
+// keepaliveParams pings idle connections so that dead replicas are noticed between calls.
+// Customer service must permit it, see Config.KeepaliveTime
+func keepaliveParams(cfg Config) keepalive.ClientParameters {
+	return keepalive.ClientParameters{
+		Time:                cfg.KeepaliveTime,
+		Timeout:             cfg.KeepaliveTimeout,
+		PermitWithoutStream: true,
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/auth"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// TokenVerifier validates bearer tokens (auth.Verifier)
+type TokenVerifier interface {
+	Verify(token string) (*auth.Claims, error)
+}
+
+// ownedMethods act on the request's user_id, which must match the token subject
+var ownedMethods = map[string]bool{
+	pb.UserProfileService_GetUserSettings_FullMethodName:    true,
+	pb.UserProfileService_UpdateUserSettings_FullMethodName: true,
+	pb.UserProfileService_AnalyzeReview_FullMethodName:      true,
+}
+
+// publicMethodPrefixes are served without a token (probes, reflection)
+var publicMethodPrefixes = []string{
+	healthMethodPrefix,
+}
+
+// AuthUnaryInterceptor authenticates calls and limits owned methods to the caller's own user_id
+func AuthUnaryInterceptor(v TokenVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if ownedMethods[info.FullMethod] {
+			// Пустой user_id не чужой, а невалидный - пусть его отклонит валидация с InvalidArgument
+			if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" {
+				if err := auth.CheckSubject(ctx, r.GetUserId()); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor authenticates streaming calls; handlers check ownership themselves
+func AuthStreamInterceptor(v TokenVerifier) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isPublicMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), v)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate verifies the bearer token from metadata and stores its claims in ctx
+func authenticate(ctx context.Context, v TokenVerifier) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		token, ok = strings.CutPrefix(values[0], "bearer ")
+	}
+	if !ok || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+	claims, err := v.Verify(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	return auth.NewContext(ctx, claims), nil
+}
+
+func isPublicMethod(fullMethod string) bool {
+	for _, prefix := range publicMethodPrefixes {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
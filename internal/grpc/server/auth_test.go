@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/auth"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// fakeVerifier accepts "Bearer <subject>" tokens, except "bad"
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(token string) (*auth.Claims, error) {
+	if token == "bad" {
+		return nil, errors.New("signature is invalid")
+	}
+	return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: token}}, nil
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func callAuth(ctx context.Context, method string, req interface{}) error {
+	interceptor := AuthUnaryInterceptor(fakeVerifier{})
+	_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	return err
+}
+
+func TestAuthUnaryInterceptor_MissingToken(t *testing.T) {
+	err := callAuth(context.Background(), pb.UserProfileService_GetUserSettings_FullMethodName, &pb.GetUserSettingsRequest{UserId: "u1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthUnaryInterceptor_InvalidToken(t *testing.T) {
+	err := callAuth(withToken("bad"), pb.UserProfileService_GetUserSettings_FullMethodName, &pb.GetUserSettingsRequest{UserId: "u1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAuthUnaryInterceptor_OwnUser(t *testing.T) {
+	err := callAuth(withToken("u1"), pb.UserProfileService_UpdateUserSettings_FullMethodName, &pb.UpdateUserSettingsRequest{UserId: "u1"})
+	assert.NoError(t, err)
+}
+
+func TestAuthUnaryInterceptor_OtherUser(t *testing.T) {
+	err := callAuth(withToken("u1"), pb.UserProfileService_AnalyzeReview_FullMethodName, &pb.AnalyzeReviewRequest{UserId: "u2"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestAuthUnaryInterceptor_EmptyUserLeftToValidation(t *testing.T) {
+	err := callAuth(withToken("u1"), pb.UserProfileService_AnalyzeReview_FullMethodName, &pb.AnalyzeReviewRequest{})
+	assert.NoError(t, err)
+}
+
+func TestAuthUnaryInterceptor_PublicMethod(t *testing.T) {
+	err := callAuth(context.Background(), "/grpc.health.v1.Health/Check", nil)
+	assert.NoError(t, err)
+}
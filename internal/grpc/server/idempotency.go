@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"api-gateway/internal/auth"
+	"api-gateway/internal/metrics"
+	redisstorage "api-gateway/internal/storage/redis"
+	"api-gateway/internal/validation"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+const (
+	// IdempotencyKeyHeader is the metadata key (and HTTP header) that makes a call safe to retry
+	IdempotencyKeyHeader = "idempotency-key"
+	// IdempotentReplayHeader is set in response metadata when the response was stored by an earlier call
+	IdempotentReplayHeader = "idempotent-replayed"
+	// maxIdempotencyKeyLength bounds client-chosen keys; a UUID takes 36
+	maxIdempotencyKeyLength = 255
+	// idempotencyWriteTimeout bounds storing the outcome after the caller may have gone away
+	idempotencyWriteTimeout = 2 * time.Second
+)
+
+// idempotentMethods honor IdempotencyKeyHeader: each of their calls creates something new
+var idempotentMethods = map[string]bool{
+	pb.UserProfileService_AnalyzeReview_FullMethodName:     true,
+	pb.UserProfileService_CreateUserProfile_FullMethodName: true,
+}
+
+// IdempotencyUnaryInterceptor stores the first successful response of a call carrying
+// IdempotencyKeyHeader for ttl and returns it to retries with the same key. A retry
+// arriving while the first call is in flight fails with Aborted, a key reused with
+// a different request fails with AlreadyExists. Failed calls are not stored, so they
+// may be retried with the same key. Keys are scoped by method and caller.
+// An in-flight call holds its key until its deadline, or for lease if it has none,
+// so that the key is freed should the gateway die before the call completes.
+// If the store is unavailable the call proceeds without the guarantee
+func IdempotencyUnaryInterceptor(store redisstorage.IdempotencyStore, ttl, lease time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !idempotentMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		key, err := idempotencyKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg, ok := req.(proto.Message)
+		if key == "" || !ok {
+			return handler(ctx, req)
+		}
+		fingerprint, err := requestFingerprint(msg)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to fingerprint request: %v", err)
+		}
+
+		scoped := scopedIdempotencyKey(ctx, info.FullMethod, req, key)
+		owner := uuid.New().String()
+		existing, err := store.Claim(ctx, scoped, redisstorage.IdempotencyRecord{Fingerprint: fingerprint, Owner: owner}, claimLease(ctx, lease))
+		if err != nil {
+			// Лучше возможный дубль, чем отказ в вызове из-за недоступного Redis
+			slog.WarnContext(ctx, "idempotency key check failed, handling call without it", "error", err)
+			metrics.IdempotencyRequests.WithLabelValues(info.FullMethod, "error").Inc()
+			return handler(ctx, req)
+		}
+		if existing != nil {
+			return replay(ctx, info.FullMethod, existing, fingerprint)
+		}
+		metrics.IdempotencyRequests.WithLabelValues(info.FullMethod, "new").Inc()
+
+		resp, err := handler(ctx, req)
+		// Клиент мог уже отвалиться по таймауту - именно его повтор и должен получить сохраненный ответ
+		wctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+		defer cancel()
+		if err != nil {
+			if rErr := store.Release(wctx, scoped, owner); rErr != nil {
+				slog.WarnContext(ctx, "failed to release idempotency key", "error", rErr)
+			}
+			return nil, err
+		}
+		if err := saveResponse(wctx, store, scoped, owner, fingerprint, resp, ttl); err != nil {
+			slog.WarnContext(ctx, "failed to store idempotent response", "error", err)
+		}
+		return resp, nil
+	}
+}
+
+// idempotencyKey returns the key sent by the caller, if any
+func idempotencyKey(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IdempotencyKeyHeader)
+	if len(values) == 0 {
+		return "", nil
+	}
+	if len(values[0]) > maxIdempotencyKeyLength {
+		return "", validation.Invalid(&errdetails.BadRequest_FieldViolation{
+			Field:       IdempotencyKeyHeader,
+			Description: "must be at most 255 characters",
+		})
+	}
+	return values[0], nil
+}
+
+// claimLease returns how long a call may hold its key: until its deadline plus the time
+// to store the outcome, or lease when the caller set no deadline
+func claimLease(ctx context.Context, lease time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return lease
+	}
+	return time.Until(deadline) + idempotencyWriteTimeout
+}
+
+// scopedIdempotencyKey keeps keys of different methods and callers apart. The caller is
+// the authenticated subject or, with authentication disabled, the user the request is for
+func scopedIdempotencyKey(ctx context.Context, method string, req interface{}, key string) string {
+	subject := ""
+	if claims, ok := auth.FromContext(ctx); ok {
+		subject = claims.Subject
+	} else if r, ok := req.(interface{ GetUserId() string }); ok {
+		subject = r.GetUserId()
+	}
+	h := sha256.New()
+	for _, part := range []string{method, subject, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestFingerprint hashes the request payload to detect a key reused for another request
+func requestFingerprint(msg proto.Message) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// replay answers a call whose key was claimed before
+func replay(ctx context.Context, method string, rec *redisstorage.IdempotencyRecord, fingerprint string) (interface{}, error) {
+	if rec.Fingerprint != fingerprint {
+		metrics.IdempotencyRequests.WithLabelValues(method, "mismatch").Inc()
+		return nil, status.Error(codes.AlreadyExists, "idempotency key was already used with a different request")
+	}
+	if !rec.Completed() {
+		metrics.IdempotencyRequests.WithLabelValues(method, "in_flight").Inc()
+		return nil, status.Error(codes.Aborted, "a request with this idempotency key is still in progress")
+	}
+	var stored anypb.Any
+	if err := proto.Unmarshal(rec.Response, &stored); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode stored response: %v", err)
+	}
+	resp, err := stored.UnmarshalNew()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode stored response: %v", err)
+	}
+	metrics.IdempotencyRequests.WithLabelValues(method, "replayed").Inc()
+	// Вне gRPC-запроса (например, в тестах) заголовок выставить нельзя - это не ошибка
+	_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayHeader, "true"))
+	return resp, nil
+}
+
+// saveResponse replaces owner's claim on key with the response
+func saveResponse(ctx context.Context, store redisstorage.IdempotencyStore, key, owner, fingerprint string, resp interface{}, ttl time.Duration) error {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "response %T is not a proto message", resp)
+	}
+	stored, err := anypb.New(msg)
+	if err != nil {
+		return err
+	}
+	b, err := proto.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return store.Complete(ctx, key, owner, redisstorage.IdempotencyRecord{Fingerprint: fingerprint, Response: b}, ttl)
+}
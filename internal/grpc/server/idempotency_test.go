@@ -0,0 +1,263 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"api-gateway/internal/auth"
+	redisstorage "api-gateway/internal/storage/redis"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// memoryIdempotencyStore keeps idempotency records in a map
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]redisstorage.IdempotencyRecord
+	leases  []time.Duration
+	err     error
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]redisstorage.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Claim(ctx context.Context, key string, rec redisstorage.IdempotencyRecord, lease time.Duration) (*redisstorage.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if existing, ok := s.records[key]; ok {
+		return &existing, nil
+	}
+	s.records[key] = rec
+	s.leases = append(s.leases, lease)
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key, owner string, rec redisstorage.IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.records[key]; !ok || current.Owner != owner || current.Completed() {
+		return redisstorage.ErrClaimLost
+	}
+	s.records[key] = rec
+	return nil
+}
+
+// expire drops all claims as if their leases ran out
+func (s *memoryIdempotencyStore) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, rec := range s.records {
+		if !rec.Completed() {
+			delete(s.records, key)
+		}
+	}
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[key]; ok && rec.Owner == owner && !rec.Completed() {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func idempotentCall(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, key))
+}
+
+var analyzeInfo = &grpc.UnaryServerInfo{FullMethod: pb.UserProfileService_AnalyzeReview_FullMethodName}
+
+func TestIdempotencyUnaryInterceptor_ReplaysResponse(t *testing.T) {
+	interceptor := IdempotencyUnaryInterceptor(newMemoryIdempotencyStore(), time.Hour, time.Minute)
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return &pb.AnalyzeReviewResponse{ReviewId: fmt.Sprintf("r%d", calls), Status: "QUEUED"}, nil
+	}
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+
+	first, err := interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	retry, err := interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.True(t, proto.Equal(first.(proto.Message), retry.(proto.Message)))
+
+	// Другой ключ - новый вызов
+	_, err = interceptor(idempotentCall("k2"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyUnaryInterceptor_Conflicts(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	interceptor := IdempotencyUnaryInterceptor(store, time.Hour, time.Minute)
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+
+	// Первый вызов еще выполняется, пока приходит повтор
+	var inFlight, mismatch error
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		_, inFlight = interceptor(idempotentCall("k1"), req, analyzeInfo, nil)
+		_, mismatch = interceptor(idempotentCall("k1"), &pb.AnalyzeReviewRequest{UserId: "u1", Text: "other"}, analyzeInfo, nil)
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+	_, err := interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+
+	assert.Equal(t, codes.Aborted, status.Code(inFlight))
+	assert.Equal(t, codes.AlreadyExists, status.Code(mismatch))
+
+	// После завершения другой payload по-прежнему конфликтует
+	_, err = interceptor(idempotentCall("k1"), &pb.AnalyzeReviewRequest{UserId: "u1", Text: "other"}, analyzeInfo, nil)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestIdempotencyUnaryInterceptor_FailedCallCanBeRetried(t *testing.T) {
+	interceptor := IdempotencyUnaryInterceptor(newMemoryIdempotencyStore(), time.Hour, time.Minute)
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+	fail := true
+	calls := 0
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		calls++
+		if fail {
+			return nil, status.Error(codes.Unavailable, "review queue is temporarily unavailable")
+		}
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+
+	_, err := interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	fail = false
+	_, err = interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyUnaryInterceptor_ScopedByCaller(t *testing.T) {
+	interceptor := IdempotencyUnaryInterceptor(newMemoryIdempotencyStore(), time.Hour, time.Minute)
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+	calls := 0
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		calls++
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+	as := func(subject string) context.Context {
+		return auth.NewContext(idempotentCall("k1"), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}})
+	}
+
+	_, err := interceptor(as("alice"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	_, err = interceptor(as("bob"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	_, err = interceptor(idempotentCall("k1"), req, &grpc.UnaryServerInfo{FullMethod: pb.UserProfileService_CreateUserProfile_FullMethodName}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Без аутентификации вызывающего отличает user_id запроса
+	_, err = interceptor(idempotentCall("k1"), &pb.AnalyzeReviewRequest{UserId: "u2", Text: "nice"}, analyzeInfo, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+	_, err = interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 5, calls, "u1 without auth is not the authenticated alice or bob")
+	_, err = interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 5, calls)
+}
+
+func TestIdempotencyUnaryInterceptor_ExpiredClaimDoesNotOverwrite(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	interceptor := IdempotencyUnaryInterceptor(store, time.Hour, time.Minute)
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+
+	// Пока первый вызов висел, его аренда истекла и ключ занял повтор
+	var second interface{}
+	slow := func(ctx context.Context, r interface{}) (interface{}, error) {
+		store.expire()
+		var err error
+		second, err = interceptor(idempotentCall("k1"), req, analyzeInfo, func(ctx context.Context, r interface{}) (interface{}, error) {
+			return &pb.AnalyzeReviewResponse{ReviewId: "r2"}, nil
+		})
+		require.NoError(t, err)
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+	_, err := interceptor(idempotentCall("k1"), req, analyzeInfo, slow)
+	require.NoError(t, err)
+
+	// Сохранен ответ того, кто держал ключ
+	replayed, err := interceptor(idempotentCall("k1"), req, analyzeInfo, nil)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(second.(proto.Message), replayed.(proto.Message)))
+}
+
+func TestIdempotencyUnaryInterceptor_LeaseFollowsDeadline(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	interceptor := IdempotencyUnaryInterceptor(store, time.Hour, time.Minute)
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+
+	_, err := interceptor(idempotentCall("k1"), &pb.AnalyzeReviewRequest{UserId: "u1"}, analyzeInfo, handler)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(idempotentCall("k2"), 10*time.Minute)
+	defer cancel()
+	_, err = interceptor(ctx, &pb.AnalyzeReviewRequest{UserId: "u1"}, analyzeInfo, handler)
+	require.NoError(t, err)
+
+	require.Len(t, store.leases, 2)
+	assert.Equal(t, time.Minute, store.leases[0])
+	assert.Greater(t, store.leases[1], 10*time.Minute-time.Second)
+	assert.LessOrEqual(t, store.leases[1], 10*time.Minute+idempotencyWriteTimeout)
+}
+
+func TestIdempotencyUnaryInterceptor_Passthrough(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	interceptor := IdempotencyUnaryInterceptor(store, time.Hour, time.Minute)
+	calls := 0
+	handler := func(ctx context.Context, r interface{}) (interface{}, error) {
+		calls++
+		return &pb.AnalyzeReviewResponse{ReviewId: "r1"}, nil
+	}
+	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}
+
+	// Без ключа
+	_, err := interceptor(context.Background(), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	// Метод не из списка
+	_, err = interceptor(idempotentCall("k1"), &pb.GetUserSettingsRequest{UserId: "u1"},
+		&grpc.UnaryServerInfo{FullMethod: pb.UserProfileService_GetUserSettings_FullMethodName}, handler)
+	require.NoError(t, err)
+	assert.Empty(t, store.records)
+
+	// Недоступное хранилище не блокирует вызовы
+	store.err = errors.New("redis down")
+	_, err = interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	_, err = interceptor(idempotentCall("k1"), req, analyzeInfo, handler)
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+
+	_, err = interceptor(idempotentCall(strings.Repeat("k", maxIdempotencyKeyLength+1)), req, analyzeInfo, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
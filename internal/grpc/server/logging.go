@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/logging"
+)
+
+// healthMethodPrefix matches health probes, which are logged at debug level
+const healthMethodPrefix = "/grpc.health.v1.Health/"
+
+// LoggingUnaryInterceptor tags the call context with its request ID, method, user_id and
+// review_id, echoes the request ID in response headers and logs the outcome of the call
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = requestContext(ctx, info.FullMethod)
+		if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" {
+			ctx = logging.With(ctx, slog.String(logging.KeyUserID, r.GetUserId()))
+		}
+		if r, ok := req.(interface{ GetReviewId() string }); ok && r.GetReviewId() != "" {
+			ctx = logging.With(ctx, slog.String(logging.KeyReviewID, r.GetReviewId()))
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor does the same for streaming calls; handlers add user_id and review_id
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := requestContext(ss.Context(), info.FullMethod)
+		start := time.Now()
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// requestContext picks up the caller's request ID or generates one
+func requestContext(ctx context.Context, method string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if v := md.Get(logging.RequestIDHeader); len(v) > 0 {
+		id = v[0]
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	// Вне gRPC-запроса заголовок выставить нельзя - это не ошибка
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, id))
+	return logging.With(ctx, slog.String(logging.KeyRequestID, id), slog.String(logging.KeyMethod, method))
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if strings.HasPrefix(method, healthMethodPrefix) {
+		// Пробы приходят каждые несколько секунд - не засоряем ими лог
+		level = slog.LevelDebug
+	}
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.ResourceExhausted:
+	default:
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	slog.LogAttrs(ctx, level, "rpc finished", attrs...)
+}
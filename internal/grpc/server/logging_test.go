@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"api-gateway/internal/logging"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+func callLogging(ctx context.Context) string {
+	var got string
+	interceptor := LoggingUnaryInterceptor()
+	_, _ = interceptor(ctx, &pb.GetUserSettingsRequest{UserId: "u1"}, &grpc.UnaryServerInfo{FullMethod: pb.UserProfileService_GetUserSettings_FullMethodName},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = logging.RequestID(ctx)
+			return "ok", nil
+		})
+	return got
+}
+
+func TestLoggingUnaryInterceptor_UsesIncomingRequestID(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-42"))
+	assert.Equal(t, "req-42", callLogging(ctx))
+}
+
+func TestLoggingUnaryInterceptor_GeneratesRequestID(t *testing.T) {
+	assert.NotEmpty(t, callLogging(context.Background()))
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/metrics"
+)
+
+func TestMetricsUnaryInterceptor_CountsByCode(t *testing.T) {
+	const method = "/test.Service/Method"
+	interceptor := MetricsUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RPCHandled.WithLabelValues(method, codes.OK.String())))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RPCHandled.WithLabelValues(method, codes.NotFound.String())))
+	assert.Positive(t, testutil.CollectAndCount(metrics.RPCDuration))
+}
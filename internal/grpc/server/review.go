@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	reviewpb "api-gateway/api/review"
+	"api-gateway/internal/auth"
+	"api-gateway/internal/logging"
+)
+
+// ReviewService defines the interface for review status lookups and subscriptions
+type ReviewService interface {
+	GetReviewStatus(ctx context.Context, req *reviewpb.GetReviewStatusRequest) (*reviewpb.ReviewStatus, error)
+	WatchReview(ctx context.Context, req *reviewpb.WatchReviewRequest, send func(*reviewpb.ReviewStatus) error) error
+}
+
+// ReviewServer implements gRPC server for ReviewService
+type ReviewServer struct {
+	reviewpb.UnimplementedReviewServiceServer
+	service ReviewService
+
+	// closing is cancelled by CloseStreams and ends open WatchReview streams
+	closing      context.Context
+	closeStreams context.CancelFunc
+}
+
+func NewReviewServer(svc ReviewService) *ReviewServer {
+	s := &ReviewServer{service: svc}
+	s.closing, s.closeStreams = context.WithCancel(context.Background())
+	return s
+}
+
+// CloseStreams ends open WatchReview streams with Unavailable, so a graceful stop
+// only waits for unary calls; clients are expected to resubscribe elsewhere
+func (s *ReviewServer) CloseStreams() {
+	s.closeStreams()
+}
+
+// Register registers server on grpcServer
+func (s *ReviewServer) Register(grpcServer *grpc.Server) {
+	reviewpb.RegisterReviewServiceServer(grpcServer, s)
+}
+
+// GetReviewStatus returns the lifecycle state of a review owned by the caller
+func (s *ReviewServer) GetReviewStatus(ctx context.Context, req *reviewpb.GetReviewStatusRequest) (*reviewpb.ReviewStatus, error) {
+	resp, err := s.service.GetReviewStatus(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReviewOwner(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WatchReview streams review state transitions of a review owned by the caller
+func (s *ReviewServer) WatchReview(req *reviewpb.WatchReviewRequest, stream grpc.ServerStreamingServer[reviewpb.ReviewStatus]) error {
+	ctx, cancel := context.WithCancel(logging.With(stream.Context(), slog.String(logging.KeyReviewID, req.GetReviewId())))
+	defer cancel()
+	stop := context.AfterFunc(s.closing, cancel)
+	defer stop()
+
+	// Чужой отзыв отклоняем до подписки, а не держим поток до первого обновления
+	if _, err := s.GetReviewStatus(ctx, &reviewpb.GetReviewStatusRequest{ReviewId: req.GetReviewId()}); err != nil {
+		return err
+	}
+	err := s.service.WatchReview(ctx, req, func(update *reviewpb.ReviewStatus) error {
+		if err := checkReviewOwner(ctx, update); err != nil {
+			return err
+		}
+		return stream.Send(update)
+	})
+	if s.closing.Err() != nil && stream.Context().Err() == nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return err
+}
+
+// checkReviewOwner answers a review of another user with the same NotFound as a missing one,
+// so that callers cannot probe which review IDs exist
+func checkReviewOwner(ctx context.Context, review *reviewpb.ReviewStatus) error {
+	if err := auth.CheckSubject(ctx, review.GetUserId()); err != nil {
+		return status.Errorf(codes.NotFound, "review %s not found", review.GetReviewId())
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	reviewpb "api-gateway/api/review"
+	"api-gateway/internal/auth"
+)
+
+// fakeReviewService serves reviews from a map and records subscriptions
+type fakeReviewService struct {
+	reviews map[string]*reviewpb.ReviewStatus
+	watched bool
+}
+
+func (f *fakeReviewService) GetReviewStatus(ctx context.Context, req *reviewpb.GetReviewStatusRequest) (*reviewpb.ReviewStatus, error) {
+	review, ok := f.reviews[req.ReviewId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "review %s not found", req.ReviewId)
+	}
+	return review, nil
+}
+
+func (f *fakeReviewService) WatchReview(ctx context.Context, req *reviewpb.WatchReviewRequest, send func(*reviewpb.ReviewStatus) error) error {
+	f.watched = true
+	review, err := f.GetReviewStatus(ctx, &reviewpb.GetReviewStatusRequest{ReviewId: req.ReviewId})
+	if err != nil {
+		return err
+	}
+	return send(review)
+}
+
+// sendStream collects sent messages
+type sendStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*reviewpb.ReviewStatus
+}
+
+func (s *sendStream) Context() context.Context { return s.ctx }
+
+func (s *sendStream) Send(m *reviewpb.ReviewStatus) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func asUser(subject string) context.Context {
+	return auth.NewContext(context.Background(), &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}})
+}
+
+func newTestReviewServer() (*ReviewServer, *fakeReviewService) {
+	svc := &fakeReviewService{reviews: map[string]*reviewpb.ReviewStatus{
+		"r1": {ReviewId: "r1", UserId: "alice", Status: "DONE"},
+	}}
+	return NewReviewServer(svc), svc
+}
+
+func TestGetReviewStatus_ForeignReviewLooksMissing(t *testing.T) {
+	srv, _ := newTestReviewServer()
+
+	resp, err := srv.GetReviewStatus(asUser("alice"), &reviewpb.GetReviewStatusRequest{ReviewId: "r1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "r1", resp.ReviewId)
+
+	_, foreign := srv.GetReviewStatus(asUser("bob"), &reviewpb.GetReviewStatusRequest{ReviewId: "r1"})
+	_, missing := srv.GetReviewStatus(asUser("bob"), &reviewpb.GetReviewStatusRequest{ReviewId: "r2"})
+	assert.Equal(t, codes.NotFound, status.Code(foreign))
+	assert.Equal(t, codes.NotFound, status.Code(missing))
+	assert.Equal(t, "review r1 not found", status.Convert(foreign).Message(), "same wording as a missing review")
+}
+
+func TestWatchReview_ForeignReviewRejectedBeforeSubscribing(t *testing.T) {
+	srv, svc := newTestReviewServer()
+
+	stream := &sendStream{ctx: asUser("bob")}
+	err := srv.WatchReview(&reviewpb.WatchReviewRequest{ReviewId: "r1"}, stream)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.False(t, svc.watched)
+	assert.Empty(t, stream.sent)
+
+	stream = &sendStream{ctx: asUser("alice")}
+	assert.NoError(t, srv.WatchReview(&reviewpb.WatchReviewRequest{ReviewId: "r1"}, stream))
+	assert.True(t, svc.watched)
+	assert.Len(t, stream.sent, 1)
+}
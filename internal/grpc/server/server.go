@@ -2,7 +2,8 @@ package server
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 
 	"google.golang.org/grpc"
@@ -57,14 +58,43 @@ func (s *Server) AnalyzeReview(ctx context.Context, req *pb.AnalyzeReviewRequest
 	return s.service.AnalyzeReview(ctx, req)
 }
 
-// Run starts the grpc server
-func Run(listenAddr string, srv *Server) error {
+// Registrar is implemented by every service served from Run
+type Registrar interface {
+	Register(grpcServer *grpc.Server)
+}
+
+// NewGRPCServer creates a grpc server with all given services registered
+func NewGRPCServer(opts []grpc.ServerOption, services ...Registrar) *grpc.Server {
+	grpcServer := grpc.NewServer(opts...)
+	for _, svc := range services {
+		svc.Register(grpcServer)
+	}
+	return grpcServer
+}
+
+// Run serves grpcServer on listenAddr until it is stopped
+func Run(listenAddr string, grpcServer *grpc.Server) error {
 	l, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		return err
 	}
-	grpcServer := grpc.NewServer()
-	srv.Register(grpcServer)
-	log.Printf("gRPC server listening on %s", listenAddr)
+	slog.Info("gRPC server listening", "addr", listenAddr)
 	return grpcServer.Serve(l)
 }
+
+// Stop lets in-flight calls finish until ctx is done, then closes the remaining ones
+func Stop(ctx context.Context, grpcServer *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		<-done
+		return fmt.Errorf("graceful stop: %w", ctx.Err())
+	}
+}
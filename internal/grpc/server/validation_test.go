@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	reviewpb "api-gateway/api/review"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+func TestValidationUnaryInterceptor(t *testing.T) {
+	interceptor := ValidationUnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: pb.UserProfileService_AnalyzeReview_FullMethodName}
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return &pb.AnalyzeReviewResponse{}, nil
+	}
+
+	_, err := interceptor(context.Background(), &pb.AnalyzeReviewRequest{UserId: "u1"}, info, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.False(t, called)
+
+	_, err = interceptor(context.Background(), &pb.AnalyzeReviewRequest{UserId: "u1", Text: "nice"}, info, handler)
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+// recvStream hands out a single message
+type recvStream struct {
+	grpc.ServerStream
+	msg *reviewpb.WatchReviewRequest
+}
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	m.(*reviewpb.WatchReviewRequest).ReviewId = s.msg.ReviewId
+	return nil
+}
+
+func TestValidationStreamInterceptor(t *testing.T) {
+	interceptor := ValidationStreamInterceptor()
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		return ss.RecvMsg(&reviewpb.WatchReviewRequest{})
+	}
+
+	err := interceptor(nil, &recvStream{msg: &reviewpb.WatchReviewRequest{}}, &grpc.StreamServerInfo{}, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = interceptor(nil, &recvStream{msg: &reviewpb.WatchReviewRequest{ReviewId: "r1"}}, &grpc.StreamServerInfo{}, handler)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,237 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Service names reported by the health server in addition to registered gRPC services.
+// The empty name reports overall readiness, as probes without a service name expect.
+// Liveness never depends on dependencies: restarting the gateway does not fix Redis
+const (
+	ServiceLiveness  = "liveness"
+	ServiceReadiness = "readiness"
+)
+
+// livenessRounds is how many check intervals may pass without a completed round
+// before the process is considered stuck
+const livenessRounds = 3
+
+// CheckFunc reports whether a dependency is usable
+type CheckFunc func(ctx context.Context) error
+
+// Result is the latest outcome of a check
+type Result struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Checker runs dependency checks in the background and publishes their outcome
+// through the standard grpc.health.v1.Health service and to HTTP probes
+type Checker struct {
+	interval time.Duration
+	timeout  time.Duration
+	server   *grpchealth.Server
+
+	mu        sync.RWMutex
+	checks    map[string]CheckFunc
+	results   map[string]Result
+	services  map[string][]string
+	lastRound time.Time
+	draining  atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a checker that runs every interval, giving each check up to timeout
+func New(interval, timeout time.Duration) *Checker {
+	c := &Checker{
+		interval: interval,
+		timeout:  timeout,
+		server:   grpchealth.NewServer(),
+		checks:   make(map[string]CheckFunc),
+		results:  make(map[string]Result),
+		services: make(map[string][]string),
+	}
+	for _, svc := range []string{"", ServiceReadiness, ServiceLiveness} {
+		c.server.SetServingStatus(svc, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return c
+}
+
+// Add registers a named dependency check; call before Start
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Service reports a gRPC service as serving only while the named checks pass
+func (c *Checker) Service(service string, checks ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services[service] = checks
+	c.server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// Register registers the health service on grpcServer (see grpcserver.Registrar)
+func (c *Checker) Register(grpcServer *grpc.Server) {
+	healthpb.RegisterHealthServer(grpcServer, c.server)
+}
+
+// Start runs the first round of checks right away and then every interval until Close
+func (c *Checker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.runChecks(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops the background checks
+func (c *Checker) Close() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Shutdown reports every service as not serving from now on, e.g. while the server drains
+func (c *Checker) Shutdown() {
+	c.draining.Store(true)
+	c.server.Shutdown()
+}
+
+// Live reports whether background checks are still making progress
+func (c *Checker) Live() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !c.lastRound.IsZero() && time.Since(c.lastRound) < livenessRounds*c.interval
+}
+
+// Ready reports whether every check passed in the latest round and the server is not draining
+func (c *Checker) Ready() bool {
+	if c.draining.Load() {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.allHealthy(nil)
+}
+
+// Results returns the latest outcome of every check
+func (c *Checker) Results() map[string]Result {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]Result, len(c.results))
+	for name, r := range c.results {
+		out[name] = r
+	}
+	return out
+}
+
+func (c *Checker) runChecks(ctx context.Context) {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	// Проверки независимы - гоняем параллельно, чтобы одна зависшая не задерживала остальные
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, name)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, name := range names {
+		prev, seen := c.results[name]
+		if !seen || prev.Healthy != results[i].Healthy {
+			if results[i].Healthy {
+				slog.Info("dependency is healthy", "check", name)
+			} else {
+				slog.Warn("dependency is unhealthy", "check", name, "error", results[i].Error)
+			}
+		}
+		c.results[name] = results[i]
+	}
+	c.lastRound = time.Now()
+
+	ready := servingStatus(c.allHealthy(nil))
+	c.server.SetServingStatus("", ready)
+	c.server.SetServingStatus(ServiceReadiness, ready)
+	c.server.SetServingStatus(ServiceLiveness, healthpb.HealthCheckResponse_SERVING)
+	for service, deps := range c.services {
+		c.server.SetServingStatus(service, servingStatus(c.allHealthy(deps)))
+	}
+}
+
+func (c *Checker) runCheck(ctx context.Context, name string) Result {
+	c.mu.RLock()
+	check := c.checks[name]
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res := Result{Healthy: true, CheckedAt: time.Now()}
+	if err := check(ctx); err != nil {
+		res.Healthy = false
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// allHealthy reports whether the named checks (all checks if names is nil) passed; c.mu must be held
+func (c *Checker) allHealthy(names []string) bool {
+	if names == nil {
+		for name := range c.checks {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if r, ok := c.results[name]; !ok || !r.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatusOf(t *testing.T, c *Checker, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := c.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("check %q: %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestChecker_TracksDependencies(t *testing.T) {
+	var kafkaDown atomic.Bool
+	kafkaDown.Store(true)
+
+	c := New(10*time.Millisecond, time.Second)
+	c.Add("redis", func(ctx context.Context) error { return nil })
+	c.Add("kafka", func(ctx context.Context) error {
+		if kafkaDown.Load() {
+			return errors.New("no brokers")
+		}
+		return nil
+	})
+	c.Service("review.ReviewService", "redis")
+	c.Service("user.UserProfileService", "redis", "kafka")
+
+	assert.False(t, c.Ready(), "not ready before the first round")
+	c.Start()
+	defer c.Close()
+
+	assert.Eventually(t, c.Live, time.Second, 5*time.Millisecond)
+	assert.False(t, c.Ready())
+	assert.Equal(t, "no brokers", c.Results()["kafka"].Error)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatusOf(t, c, "review.ReviewService"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatusOf(t, c, "user.UserProfileService"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatusOf(t, c, ServiceReadiness))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, servingStatusOf(t, c, ServiceLiveness))
+
+	kafkaDown.Store(false)
+	assert.Eventually(t, c.Ready, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return servingStatusOf(t, c, "") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestChecker_Shutdown(t *testing.T) {
+	var rounds atomic.Int32
+	c := New(10*time.Millisecond, time.Second)
+	c.Add("redis", func(ctx context.Context) error {
+		rounds.Add(1)
+		return nil
+	})
+	c.Start()
+	defer c.Close()
+	assert.Eventually(t, c.Ready, time.Second, 5*time.Millisecond)
+
+	c.Shutdown()
+	assert.False(t, c.Ready())
+	// Раунды идут по очереди: начало второго следующего значит, что один полный раунд прошел после Shutdown
+	after := rounds.Load()
+	assert.Eventually(t, func() bool { return rounds.Load() >= after+2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, servingStatusOf(t, c, ""))
+}
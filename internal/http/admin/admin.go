@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"api-gateway/internal/health"
+)
+
+// probeResponse is the body of /healthz and /readyz
+type probeResponse struct {
+	Status string                   `json:"status"`
+	Checks map[string]health.Result `json:"checks,omitempty"`
+}
+
+// New returns the handler of the admin listener, kept off the public HTTP port.
+// /healthz is the liveness probe, /readyz the readiness probe
+func New(checker *health.Checker) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, checker.Live(), nil)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, checker.Ready(), checker.Results())
+	})
+	return mux
+}
+
+func writeProbe(w http.ResponseWriter, ok bool, checks map[string]health.Result) {
+	resp := probeResponse{Status: "SERVING", Checks: checks}
+	code := http.StatusOK
+	if !ok {
+		resp.Status = "NOT_SERVING"
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Warn("failed to write probe response", "error", err)
+	}
+}
+
+// NewHTTPServer creates the admin listener; stop it with Shutdown
+func NewHTTPServer(listenAddr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              listenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// Run serves httpServer until it is shut down
+func Run(httpServer *http.Server) error {
+	slog.Info("admin listener started", "addr", httpServer.Addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
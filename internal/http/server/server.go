@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	reviewpb "api-gateway/api/review"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+const (
+	// maxBodyBytes limits the size of incoming JSON payloads
+	maxBodyBytes = 1 << 20
+	// metadataHeaderPrefix marks arbitrary headers that should be forwarded as gRPC metadata
+	metadataHeaderPrefix = "Grpc-Metadata-"
+	// staleMetadataKey marks responses served from an expired cache entry (see service.StaleHeader)
+	staleMetadataKey = "x-cache-stale"
+	// retryAfterMetadataKey carries the rate limit back-off in seconds (see ratelimit.RetryAfterHeader)
+	retryAfterMetadataKey = "retry-after"
+	// updateMaskMetadataKey carries the update_mask query parameter (see service.UpdateMaskHeader)
+	updateMaskMetadataKey = "x-update-mask"
+	// etagMetadataKey is the version of returned settings (see service.ETagHeader)
+	etagMetadataKey = "etag"
+	// requestIDMetadataKey is the request ID assigned by the gRPC server (see logging.RequestIDHeader)
+	requestIDMetadataKey = "x-request-id"
+)
+
+// forwardedHeaders are HTTP headers passed to the gRPC server as metadata
+var forwardedHeaders = []string{
+	"Authorization",
+	"X-Request-Id",
+	// Conditional settings updates (see service.IfMatchHeader)
+	"If-Match",
+	// Safe retries of AnalyzeReview and CreateUserProfile (see grpcserver.IdempotencyKeyHeader)
+	"Idempotency-Key",
+	// W3C trace context, so the gRPC server span joins the caller's trace
+	"Traceparent",
+	"Tracestate",
+}
+
+var (
+	marshaler   = protojson.MarshalOptions{EmitUnpopulated: true}
+	unmarshaler = protojson.UnmarshalOptions{}
+)
+
+// Server transcodes HTTP/JSON requests into UserProfileService gRPC calls
+type Server struct {
+	client  pb.UserProfileServiceClient
+	reviews reviewpb.ReviewServiceClient
+	mux     *http.ServeMux
+
+	// closing is cancelled by CloseStreams and ends open event streams
+	closing      context.Context
+	closeStreams context.CancelFunc
+}
+
+// New creates the HTTP front door on top of the gateway's gRPC clients
+func New(client pb.UserProfileServiceClient, reviews reviewpb.ReviewServiceClient) *Server {
+	s := &Server{client: client, reviews: reviews, mux: http.NewServeMux()}
+	s.closing, s.closeStreams = context.WithCancel(context.Background())
+	s.mux.HandleFunc("GET /v1/users/{id}/settings", s.getUserSettings)
+	s.mux.HandleFunc("PATCH /v1/users/{id}/settings", s.updateUserSettings)
+	s.mux.HandleFunc("POST /v1/users", s.createUserProfile)
+	s.mux.HandleFunc("POST /v1/reviews", s.analyzeReview)
+	s.mux.HandleFunc("GET /v1/reviews/{id}", s.getReviewStatus)
+	s.mux.HandleFunc("GET /v1/reviews/{id}/events", s.watchReview)
+	return s
+}
+
+// CloseStreams ends every open event stream with an error event telling the client to reconnect.
+// http.Server.Shutdown waits for handlers without cancelling them, so streams would
+// otherwise hold shutdown until its deadline
+func (s *Server) CloseStreams() {
+	s.closeStreams()
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Dial opens a client connection to the local gRPC listener so HTTP calls
+// go through the same interceptors as native gRPC clients; creds must match the listener
+func Dial(grpcAddr string, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
+	host, port, err := net.SplitHostPort(grpcAddr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return grpc.NewClient(net.JoinHostPort(host, port), grpc.WithTransportCredentials(creds))
+}
+
+// Run serves httpServer until it is shut down
+func Run(httpServer *http.Server) error {
+	slog.Info("HTTP gateway listening", "addr", httpServer.Addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NewHTTPServer creates the gateway's http.Server; stop it with Shutdown, which also closes event streams
+func NewHTTPServer(listenAddr string, srv *Server) *http.Server {
+	httpServer := &http.Server{
+		Addr:              listenAddr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	httpServer.RegisterOnShutdown(srv.CloseStreams)
+	return httpServer
+}
+
+func (s *Server) getUserSettings(w http.ResponseWriter, r *http.Request) {
+	req := &pb.GetUserSettingsRequest{UserId: r.PathValue("id")}
+	var header metadata.MD
+	resp, err := s.client.GetUserSettings(outgoingContext(r), req, grpc.Header(&header))
+	writeResponse(w, http.StatusOK, header, resp, err)
+}
+
+func (s *Server) updateUserSettings(w http.ResponseWriter, r *http.Request) {
+	req := &pb.UpdateUserSettingsRequest{}
+	if err := decodeBody(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	req.UserId = r.PathValue("id")
+	ctx := outgoingContext(r)
+	// ?update_mask=theme,font меняет только перечисленные поля
+	if mask := r.URL.Query().Get("update_mask"); mask != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, updateMaskMetadataKey, mask)
+	}
+	var header metadata.MD
+	resp, err := s.client.UpdateUserSettings(ctx, req, grpc.Header(&header))
+	writeResponse(w, http.StatusOK, header, resp, err)
+}
+
+func (s *Server) createUserProfile(w http.ResponseWriter, r *http.Request) {
+	req := &pb.CreateUserProfileRequest{}
+	if err := decodeBody(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	var header metadata.MD
+	resp, err := s.client.CreateUserProfile(outgoingContext(r), req, grpc.Header(&header))
+	writeResponse(w, http.StatusCreated, header, resp, err)
+}
+
+func (s *Server) analyzeReview(w http.ResponseWriter, r *http.Request) {
+	req := &pb.AnalyzeReviewRequest{}
+	if err := decodeBody(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	var header metadata.MD
+	resp, err := s.client.AnalyzeReview(outgoingContext(r), req, grpc.Header(&header))
+	writeResponse(w, http.StatusAccepted, header, resp, err)
+}
+
+func (s *Server) getReviewStatus(w http.ResponseWriter, r *http.Request) {
+	req := &reviewpb.GetReviewStatusRequest{ReviewId: r.PathValue("id")}
+	var header metadata.MD
+	resp, err := s.reviews.GetReviewStatus(outgoingContext(r), req, grpc.Header(&header))
+	writeResponse(w, http.StatusOK, header, resp, err)
+}
+
+// watchReview relays the WatchReview stream as server-sent events
+func (s *Server) watchReview(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, status.Error(codes.Unimplemented, "streaming is not supported"))
+		return
+	}
+	ctx, cancel := context.WithCancel(outgoingContext(r))
+	defer cancel()
+	stop := context.AfterFunc(s.closing, cancel)
+	defer stop()
+
+	req := &reviewpb.WatchReviewRequest{ReviewId: r.PathValue("id")}
+	stream, err := s.reviews.WatchReview(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Первое сообщение читаем до заголовков, чтобы ошибки вроде NotFound ушли обычным ответом
+	msg, err := stream.Recv()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		b, mErr := marshaler.Marshal(msg)
+		if mErr != nil {
+			writeEvent(w, "error", []byte(`{"code":13,"message":"failed to marshal update"}`))
+			flusher.Flush()
+			return
+		}
+		writeEvent(w, "status", b)
+		flusher.Flush()
+
+		msg, err = stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if s.closing.Err() != nil {
+				// Поток оборван нами при остановке - клиенту стоит переподключиться к другой реплике
+				err = status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if b, mErr := protojson.Marshal(status.Convert(err).Proto()); mErr == nil {
+				writeEvent(w, "error", b)
+				flusher.Flush()
+			}
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data []byte) {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		slog.Warn("failed to write event", "error", err)
+	}
+}
+
+// decodeBody reads a protojson payload; an empty body leaves msg untouched
+func decodeBody(w http.ResponseWriter, r *http.Request, msg proto.Message) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to read body: %v", err)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if err := unmarshaler.Unmarshal(body, msg); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid JSON payload: %v", err)
+	}
+	return nil
+}
+
+// outgoingContext copies selected request headers into gRPC metadata
+func outgoingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Values(h); len(v) > 0 {
+			md.Append(strings.ToLower(h), v...)
+		}
+	}
+	for h, v := range r.Header {
+		if key, ok := strings.CutPrefix(h, metadataHeaderPrefix); ok {
+			md.Append(strings.ToLower(key), v...)
+		}
+	}
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+func writeResponse(w http.ResponseWriter, code int, header metadata.MD, msg proto.Message, err error) {
+	forwardResponseHeaders(w, header)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	b, err := marshaler.Marshal(msg)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "failed to marshal response: %v", err))
+		return
+	}
+	writeJSON(w, code, b)
+}
+
+// forwardResponseHeaders exposes gRPC response metadata as Grpc-Metadata-* headers
+func forwardResponseHeaders(w http.ResponseWriter, header metadata.MD) {
+	for key, values := range header {
+		if key == "content-type" || strings.HasPrefix(key, "grpc-") {
+			continue
+		}
+		for _, v := range values {
+			w.Header().Add(metadataHeaderPrefix+key, v)
+		}
+	}
+	if v := header.Get(staleMetadataKey); len(v) > 0 && v[0] == "true" {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+	if v := header.Get(retryAfterMetadataKey); len(v) > 0 {
+		w.Header().Set("Retry-After", v[0])
+	}
+	if v := header.Get(requestIDMetadataKey); len(v) > 0 {
+		w.Header().Set("X-Request-Id", v[0])
+	}
+	if v := header.Get(etagMetadataKey); len(v) > 0 {
+		w.Header().Set("ETag", v[0])
+	}
+}
+
+// writeError renders a gRPC status as JSON with the matching HTTP code
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	b, mErr := protojson.Marshal(st.Proto())
+	if mErr != nil {
+		slog.Error("failed to marshal error status", "error", mErr)
+		b = []byte(`{"code":13,"message":"internal error"}`)
+	}
+	writeJSON(w, HTTPStatusFromCode(st.Code()), b)
+}
+
+func writeJSON(w http.ResponseWriter, code int, b []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		slog.Warn("failed to write HTTP response", "error", err)
+	}
+}
+
+// HTTPStatusFromCode maps a gRPC status code to the corresponding HTTP status
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
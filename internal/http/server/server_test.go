@@ -0,0 +1,328 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	reviewpb "api-gateway/api/review"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// MockClient mocks the generated UserProfileServiceClient
+type MockClient struct {
+	mock.Mock
+}
+
+func (m *MockClient) CreateUserProfile(ctx context.Context, in *pb.CreateUserProfileRequest, opts ...grpc.CallOption) (*pb.CreateUserProfileResponse, error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.CreateUserProfileResponse), args.Error(1)
+}
+
+func (m *MockClient) GetUserSettings(ctx context.Context, in *pb.GetUserSettingsRequest, opts ...grpc.CallOption) (*pb.GetUserSettingsResponse, error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.GetUserSettingsResponse), args.Error(1)
+}
+
+func (m *MockClient) UpdateUserSettings(ctx context.Context, in *pb.UpdateUserSettingsRequest, opts ...grpc.CallOption) (*pb.UpdateUserSettingsResponse, error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.UpdateUserSettingsResponse), args.Error(1)
+}
+
+func (m *MockClient) AnalyzeReview(ctx context.Context, in *pb.AnalyzeReviewRequest, opts ...grpc.CallOption) (*pb.AnalyzeReviewResponse, error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pb.AnalyzeReviewResponse), args.Error(1)
+}
+
+// MockReviewClient mocks the generated ReviewServiceClient
+type MockReviewClient struct {
+	mock.Mock
+}
+
+func (m *MockReviewClient) GetReviewStatus(ctx context.Context, in *reviewpb.GetReviewStatusRequest, opts ...grpc.CallOption) (*reviewpb.ReviewStatus, error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reviewpb.ReviewStatus), args.Error(1)
+}
+
+func (m *MockReviewClient) WatchReview(ctx context.Context, in *reviewpb.WatchReviewRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[reviewpb.ReviewStatus], error) {
+	args := m.Called(ctx, in)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(grpc.ServerStreamingClient[reviewpb.ReviewStatus]), args.Error(1)
+}
+
+// fakeReviewStream replays a fixed list of updates and then returns err
+type fakeReviewStream struct {
+	grpc.ClientStream
+	updates []*reviewpb.ReviewStatus
+	err     error
+}
+
+func (f *fakeReviewStream) Recv() (*reviewpb.ReviewStatus, error) {
+	if len(f.updates) == 0 {
+		return nil, f.err
+	}
+	next := f.updates[0]
+	f.updates = f.updates[1:]
+	return next, nil
+}
+
+func TestGetUserSettings_Success(t *testing.T) {
+	mockClient := new(MockClient)
+	settings := &pb.GetUserSettingsResponse{Theme: "dark", PickedModel: "gpt-4", Font: "monospace"}
+
+	mockClient.On("GetUserSettings", mock.Anything, mock.MatchedBy(func(req *pb.GetUserSettingsRequest) bool {
+		return req.UserId == "user123"
+	})).Return(settings, nil)
+
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/user123/settings", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var got pb.GetUserSettingsResponse
+	assert.NoError(t, protojson.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "dark", got.Theme)
+	assert.Equal(t, "gpt-4", got.PickedModel)
+}
+
+func TestUpdateUserSettings_UsesPathUserID(t *testing.T) {
+	mockClient := new(MockClient)
+
+	mockClient.On("UpdateUserSettings", mock.Anything, mock.MatchedBy(func(req *pb.UpdateUserSettingsRequest) bool {
+		return req.UserId == "user789" && req.Theme == "light"
+	})).Return(&pb.UpdateUserSettingsResponse{Theme: "light"}, nil)
+
+	body := strings.NewReader(`{"userId":"someone-else","theme":"light"}`)
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/users/user789/settings", body))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUpdateUserSettings_ForwardsUpdateMask(t *testing.T) {
+	mockClient := new(MockClient)
+
+	mockClient.On("UpdateUserSettings", mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return len(md.Get(updateMaskMetadataKey)) == 1 && md.Get(updateMaskMetadataKey)[0] == "theme,font"
+	}), mock.Anything).Return(&pb.UpdateUserSettingsResponse{Theme: "light"}, nil)
+
+	body := strings.NewReader(`{"theme":"light"}`)
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/users/user789/settings?update_mask=theme,font", body))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestUpdateUserSettings_IfMatch(t *testing.T) {
+	mockClient := new(MockClient)
+
+	mockClient.On("UpdateUserSettings", mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return len(md.Get("if-match")) == 1 && md.Get("if-match")[0] == `"abc"`
+	}), mock.Anything).Return(nil, status.Error(codes.FailedPrecondition, "settings were modified"))
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/users/user789/settings", strings.NewReader(`{"theme":"light"}`))
+	req.Header.Set("If-Match", `"abc"`)
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	mockClient.AssertExpectations(t)
+}
+
+func TestWriteResponse_ETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeResponse(rec, http.StatusOK, metadata.Pairs(etagMetadataKey, `"abc"`), &pb.GetUserSettingsResponse{}, nil)
+
+	assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+}
+
+func TestAnalyzeReview_ForwardsHeaders(t *testing.T) {
+	mockClient := new(MockClient)
+
+	mockClient.On("AnalyzeReview", mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return len(md.Get("authorization")) == 1 && md.Get("x-custom")[0] == "42" &&
+			len(md.Get("idempotency-key")) == 1 && md.Get("idempotency-key")[0] == "retry-1"
+	}), mock.Anything).Return(&pb.AnalyzeReviewResponse{ReviewId: "uuid-1", Status: "QUEUED"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/reviews", strings.NewReader(`{"user_id":"u1","text":"hello"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Idempotency-Key", "retry-1")
+	req.Header.Set("Grpc-Metadata-X-Custom", "42")
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"reviewId":"uuid-1"`)
+}
+
+func TestCreateUserProfile_InvalidJSON(t *testing.T) {
+	mockClient := new(MockClient)
+
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{not json`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockClient.AssertNotCalled(t, "CreateUserProfile")
+}
+
+func TestGetUserSettings_MapsStatusCode(t *testing.T) {
+	mockClient := new(MockClient)
+	mockClient.On("GetUserSettings", mock.Anything, mock.Anything).
+		Return(nil, status.Error(codes.NotFound, "user not found"))
+
+	rec := httptest.NewRecorder()
+	New(mockClient, new(MockReviewClient)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/ghost/settings", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "user not found")
+}
+
+func TestGetReviewStatus_Success(t *testing.T) {
+	mockReviews := new(MockReviewClient)
+	mockReviews.On("GetReviewStatus", mock.Anything, mock.MatchedBy(func(req *reviewpb.GetReviewStatusRequest) bool {
+		return req.ReviewId == "uuid-1"
+	})).Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: "DONE"}, nil)
+
+	rec := httptest.NewRecorder()
+	New(new(MockClient), mockReviews).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/reviews/uuid-1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"status":"DONE"`)
+}
+
+func TestWatchReview_StreamsEvents(t *testing.T) {
+	mockReviews := new(MockReviewClient)
+	stream := &fakeReviewStream{
+		updates: []*reviewpb.ReviewStatus{
+			{ReviewId: "uuid-1", Status: "QUEUED"},
+			{ReviewId: "uuid-1", Status: "DONE"},
+		},
+		err: io.EOF,
+	}
+	mockReviews.On("WatchReview", mock.Anything, mock.Anything).Return(stream, nil)
+
+	rec := httptest.NewRecorder()
+	New(new(MockClient), mockReviews).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/reviews/uuid-1/events", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "event: status\n"))
+	assert.Contains(t, rec.Body.String(), `"status":"DONE"`)
+}
+
+// blockingReviewStream sends one update and then blocks until its call is cancelled
+type blockingReviewStream struct {
+	grpc.ClientStream
+	ctx  context.Context
+	sent bool
+}
+
+func (f *blockingReviewStream) Recv() (*reviewpb.ReviewStatus, error) {
+	if !f.sent {
+		f.sent = true
+		return &reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: "QUEUED"}, nil
+	}
+	<-f.ctx.Done()
+	return nil, status.FromContextError(f.ctx.Err()).Err()
+}
+
+// blockingReviewClient opens a blockingReviewStream for every WatchReview call
+type blockingReviewClient struct {
+	MockReviewClient
+}
+
+func (c *blockingReviewClient) WatchReview(ctx context.Context, in *reviewpb.WatchReviewRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[reviewpb.ReviewStatus], error) {
+	return &blockingReviewStream{ctx: ctx}, nil
+}
+
+func TestShutdown_ClosesOpenStreams(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpServer := NewHTTPServer(ln.Addr().String(), New(new(MockClient), &blockingReviewClient{}))
+	go httpServer.Serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/v1/reviews/uuid-1/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: status\n", line)
+
+	// Открытый поток не должен держать остановку до дедлайна
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, httpServer.Shutdown(ctx))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	rest, _ := io.ReadAll(reader)
+	assert.Contains(t, string(rest), "event: error")
+	assert.Contains(t, string(rest), "server is shutting down")
+}
+
+func TestWatchReview_NotFoundBeforeStream(t *testing.T) {
+	mockReviews := new(MockReviewClient)
+	stream := &fakeReviewStream{err: status.Error(codes.NotFound, "review missing not found")}
+	mockReviews.On("WatchReview", mock.Anything, mock.Anything).Return(stream, nil)
+
+	rec := httptest.NewRecorder()
+	New(new(MockClient), mockReviews).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/reviews/missing/events", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestWriteResponse_RetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := status.Error(codes.ResourceExhausted, "user rate limit exceeded for AnalyzeReview")
+	writeResponse(rec, http.StatusAccepted, metadata.Pairs("retry-after", "12"), nil, err)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "12", rec.Header().Get("Retry-After"))
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, HTTPStatusFromCode(codes.Unavailable))
+	assert.Equal(t, http.StatusGatewayTimeout, HTTPStatusFromCode(codes.DeadlineExceeded))
+	assert.Equal(t, http.StatusTooManyRequests, HTTPStatusFromCode(codes.ResourceExhausted))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatusFromCode(codes.Unknown))
+}
@@ -0,0 +1,167 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"api-gateway/internal/logging"
+)
+
+// restartDelay is how long the consumer waits before rejoining the group after an error
+const restartDelay = 2 * time.Second
+
+// HandlerFunc processes a single message. Returning an error leaves the
+// offset uncommitted so the message is redelivered after the session restarts
+type HandlerFunc func(ctx context.Context, key string, value []byte) error
+
+// Consumer читает топик в составе consumer group и передает сообщения в HandlerFunc
+type Consumer struct {
+	group   sarama.ConsumerGroup
+	topic   string
+	handler HandlerFunc
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewConsumer joins groupID and prepares to consume topic
+func NewConsumer(brokers []string, groupID, topic string, handler HandlerFunc) (*Consumer, error) {
+	config := sarama.NewConfig()
+	// Новая группа начинает с самого старого сообщения, чтобы не потерять результаты
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	// Коммитим только отмеченные (успешно обработанные) оффсеты
+	config.Consumer.Offsets.AutoCommit.Enable = true
+	config.Consumer.Offsets.AutoCommit.Interval = time.Second
+	config.Consumer.Return.Errors = true
+
+	group, err := sarama.NewConsumerGroup(brokers, groupID, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer group: %w", err)
+	}
+
+	return &Consumer{
+		group:   group,
+		topic:   topic,
+		handler: handler,
+	}, nil
+}
+
+// Start runs the consume loop in the background until Close is called
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(2)
+	go func() {
+		defer c.wg.Done()
+		for err := range c.group.Errors() {
+			slog.Error("kafka consumer error", "topic", c.topic, "error", err)
+		}
+	}()
+	go func() {
+		defer c.wg.Done()
+		c.run(ctx)
+	}()
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	handler := &groupHandler{handler: c.handler}
+	for {
+		// Consume блокируется на время сессии и возвращается при ребалансе или ошибке
+		err := c.group.Consume(ctx, []string{c.topic}, handler)
+		if ctx.Err() != nil || errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return
+		}
+		if err != nil {
+			slog.Warn("kafka consume session ended", "topic", c.topic, "error", err)
+		}
+		// Сессия оборвалась из-за ошибки - даем зависимостям время восстановиться
+		if err != nil || handler.failed.Swap(false) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(restartDelay):
+			}
+		}
+	}
+}
+
+// Close stops consuming, commits marked offsets and leaves the group
+func (c *Consumer) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	err := c.group.Close()
+	c.wg.Wait()
+	return err
+}
+
+// groupHandler implements sarama.ConsumerGroupHandler
+type groupHandler struct {
+	handler HandlerFunc
+	failed  atomic.Bool
+}
+
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup flushes marked offsets before partitions are handed over
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if err := h.handle(session.Context(), msg); err != nil {
+				h.failed.Store(true)
+				// Не отмечаем оффсет: после перезапуска сессии сообщение придет снова
+				return fmt.Errorf("handle message %s/%d@%d: %w", msg.Topic, msg.Partition, msg.Offset, err)
+			}
+			session.MarkMessage(msg, "")
+		}
+	}
+}
+
+// handle вызывает обработчик в span'е, продолжающем трейс отправителя сообщения
+func (h *groupHandler) handle(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	headers := consumerHeaders{msg}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headers)
+	ctx = logging.WithRequestID(ctx, headers.Get(logging.RequestIDHeader))
+	ctx, span := tracer.Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.destination.partition.id", int(msg.Partition)),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+		),
+	)
+	defer span.End()
+
+	err := h.handler(ctx, string(msg.Key), msg.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return err
+}
@@ -1,15 +1,26 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"api-gateway/internal/logging"
+	"api-gateway/internal/metrics"
 )
 
 // Producer обертка над Sarama
 type Producer struct {
+	client   sarama.Client
 	producer sarama.SyncProducer
 	topic    string
 }
@@ -22,39 +33,116 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
 
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	// Отдельный клиент нужен, чтобы проверять метаданные брокеров в health check
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
 	}
 
 	return &Producer{
+		client:   client,
 		producer: producer,
 		topic:    topic,
 	}, nil
 }
 
-// SendMessage отправляет любой struct как JSON
-func (p *Producer) SendMessage(key string, value interface{}) error {
+// SendMessage отправляет любой struct как JSON.
+// Контекст трассировки из ctx уходит в заголовки сообщения, чтобы process-service продолжил трейс
+func (p *Producer) SendMessage(ctx context.Context, key string, value interface{}) error {
 	bytes, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("marshalling error: %w", err)
+		return permanentError{fmt.Errorf("marshalling error: %w", err)}
 	}
 
+	ctx, span := tracer.Start(ctx, p.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.topic),
+		),
+	)
+	defer span.End()
+
 	msg := &sarama.ProducerMessage{
 		Topic: p.topic,
 		Key:   sarama.StringEncoder(key), // Key нужен, чтобы сообщения одного юзера шли в одну партицию
 		Value: sarama.ByteEncoder(bytes),
 	}
+	headers := producerHeaders{msg}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+	if id := logging.RequestID(ctx); id != "" {
+		headers.Set(logging.RequestIDHeader, id)
+	}
 
+	start := time.Now()
 	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.KafkaSendDuration.WithLabelValues(p.topic).Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.KafkaSendFailures.WithLabelValues(p.topic).Inc()
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+		if isPermanent(err) {
+			return permanentError{fmt.Errorf("kafka send error: %w", err)}
+		}
 		return fmt.Errorf("kafka send error: %w", err)
 	}
+	span.SetAttributes(
+		attribute.Int("messaging.destination.partition.id", int(partition)),
+		attribute.Int64("messaging.kafka.offset", offset),
+	)
 
-	log.Printf("[Kafka] Sent to partition %d offset %d", partition, offset)
+	slog.DebugContext(ctx, "kafka message sent", "topic", p.topic, "partition", partition, "offset", offset)
 	return nil
 }
 
+// permanentError marks a send that fails the same way however often it is retried
+type permanentError struct {
+	error
+}
+
+// Permanent tells the outbox relay to dead-letter the message instead of retrying it
+func (permanentError) Permanent() bool { return true }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// isPermanent reports whether the broker rejected the message itself rather than failed to take it
+func isPermanent(err error) bool {
+	for _, kerr := range []sarama.KError{
+		sarama.ErrMessageSizeTooLarge,
+		sarama.ErrInvalidRecord,
+		sarama.ErrInvalidTopic,
+		sarama.ErrTopicAuthorizationFailed,
+	} {
+		if errors.Is(err, kerr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check refreshes metadata of the producer topic, which fails when no broker is reachable
+func (p *Producer) Check(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.client.RefreshMetadata(p.topic)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	if err := p.producer.Close(); err != nil {
+		p.client.Close()
+		return err
+	}
+	return p.client.Close()
 }
@@ -0,0 +1,121 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// step is a named shutdown action
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+	// timeout bounds the step on top of the shared deadline; zero means only the shared one
+	timeout time.Duration
+}
+
+// Manager runs long-lived components and stops the application in a fixed order
+// when a signal arrives or one of the components fails
+type Manager struct {
+	mu    sync.Mutex
+	steps []step
+
+	failed chan error
+	wg     sync.WaitGroup
+}
+
+// New creates a lifecycle manager
+func New() *Manager {
+	return &Manager{failed: make(chan error, 1)}
+}
+
+// Go runs a component such as a server in the background. A component returning
+// an error triggers shutdown; returning nil (e.g. after being stopped) does not
+func (m *Manager) Go(name string, run func() error) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := run(); err != nil {
+			select {
+			case m.failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// OnShutdown adds a shutdown step. Steps run one after another in the order they were added,
+// all sharing the shutdown deadline; a failing step is logged and does not stop the rest
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.OnShutdownWithin(name, 0, fn)
+}
+
+// OnShutdownWithin adds a shutdown step that may take at most timeout, so a step
+// that waits on clients (e.g. draining a server) cannot use up the budget of the steps after it
+func (m *Manager) OnShutdownWithin(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps = append(m.steps, step{name: name, fn: fn, timeout: timeout})
+}
+
+// Wait blocks until one of signals arrives or a component fails, then shuts down
+// within timeout. It returns the error of the failed component, if any
+func (m *Manager) Wait(timeout time.Duration, signals ...os.Signal) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+
+	var cause error
+	select {
+	case sig := <-quit:
+		slog.Info("shutting down", "signal", sig.String(), "timeout", timeout)
+	case cause = <-m.failed:
+		slog.Error("component failed, shutting down", "error", cause)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	m.Shutdown(ctx)
+	return cause
+}
+
+// Shutdown runs every shutdown step in order and waits for components started with Go to return
+func (m *Manager) Shutdown(ctx context.Context) {
+	m.mu.Lock()
+	steps := m.steps
+	m.steps = nil
+	m.mu.Unlock()
+
+	for _, s := range steps {
+		start := time.Now()
+		if err := s.run(ctx); err != nil {
+			slog.Error("shutdown step failed", "step", s.name, "error", err)
+			continue
+		}
+		slog.Debug("shutdown step done", "step", s.name, "duration", time.Since(start))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("components still running at shutdown deadline")
+	}
+}
+
+func (s step) run(ctx context.Context) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.fn(ctx)
+}
@@ -0,0 +1,102 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_RunsStepsInOrder(t *testing.T) {
+	m := New()
+	var order []string
+	m.OnShutdown("first", func(context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	m.OnShutdown("failing", func(context.Context) error {
+		order = append(order, "failing")
+		return errors.New("boom")
+	})
+	m.OnShutdown("last", func(context.Context) error {
+		order = append(order, "last")
+		return nil
+	})
+
+	m.Shutdown(context.Background())
+	assert.Equal(t, []string{"first", "failing", "last"}, order)
+}
+
+func TestShutdown_StepTimeoutLeavesBudgetForLaterSteps(t *testing.T) {
+	m := New()
+	m.OnShutdownWithin("stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var laterErr error
+	m.OnShutdown("later", func(ctx context.Context) error {
+		laterErr = ctx.Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m.Shutdown(ctx)
+	assert.NoError(t, laterErr, "later steps keep the shared deadline")
+}
+
+func TestWait_ComponentFailureTriggersShutdown(t *testing.T) {
+	m := New()
+	stop := make(chan struct{})
+	m.Go("server", func() error {
+		<-stop
+		return nil
+	})
+	m.Go("broken", func() error { return errors.New("listen failed") })
+	stopped := false
+	m.OnShutdown("server", func(context.Context) error {
+		stopped = true
+		close(stop)
+		return nil
+	})
+
+	err := m.Wait(time.Second, syscall.SIGUSR2)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken: listen failed")
+	assert.True(t, stopped)
+}
+
+func TestWait_Signal(t *testing.T) {
+	// Своя подписка не дает SIGUSR2 убить тестовый бинарь, пока Wait еще не подписался
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, syscall.SIGUSR2)
+	defer signal.Stop(ignored)
+
+	m := New()
+	done := make(chan error, 1)
+	go func() { done <- m.Wait(time.Second, syscall.SIGUSR2) }()
+
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	// Сигнал, пришедший до подписки Wait, ему не достанется - повторяем, пока Wait не вернется
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(2 * time.Second)
+	for {
+		require.NoError(t, p.Signal(syscall.SIGUSR2))
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			return
+		case <-tick.C:
+		case <-timeout:
+			t.Fatal("Wait did not return after signal")
+		}
+	}
+}
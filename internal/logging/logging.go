@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the metadata key (and HTTP/Kafka header) carrying the request ID
+const RequestIDHeader = "x-request-id"
+
+// Attribute keys added to every log line of a request
+const (
+	KeyRequestID = "request_id"
+	KeyMethod    = "method"
+	KeyUserID    = "user_id"
+	KeyReviewID  = "review_id"
+)
+
+// level is the minimum level of the default logger; SetLevel changes it at runtime
+var level = new(slog.LevelVar)
+
+// Setup installs the default slog logger writing to w in the given format (json or text) and level
+func Setup(w io.Writer, format, lvl string) error {
+	if err := SetLevel(lvl); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: want json or text", format)
+	}
+	slog.SetDefault(slog.New(&contextHandler{Handler: h}))
+	return nil
+}
+
+// SetLevel changes the minimum level of the logger installed by Setup
+func SetLevel(lvl string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", lvl, err)
+	}
+	level.Set(l)
+	return nil
+}
+
+// NewRequestID generates a request ID for calls that arrive without one
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+type attrsKey struct{}
+
+// With returns a context whose log lines carry attrs in addition to those already in ctx.
+// Later values replace earlier ones with the same key
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev := attrsFrom(ctx)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	for _, a := range prev {
+		if !containsKey(attrs, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// WithRequestID returns a context carrying id as the request ID; an empty id is ignored
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return With(ctx, slog.String(KeyRequestID, id))
+}
+
+// RequestID returns the request ID stored in ctx, if any
+func RequestID(ctx context.Context) string {
+	for _, a := range attrsFrom(ctx) {
+		if a.Key == KeyRequestID {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+// Inherit copies the log attributes of src into dst, e.g. for work detached from a request
+func Inherit(dst, src context.Context) context.Context {
+	if attrs := attrsFrom(src); len(attrs) > 0 {
+		return context.WithValue(dst, attrsKey{}, attrs)
+	}
+	return dst
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func containsKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the attributes stored in the record's context to every line
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if attrs := attrsFrom(ctx); len(attrs) > 0 {
+			r = r.Clone()
+			r.AddAttrs(attrs...)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
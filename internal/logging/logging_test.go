@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup_AddsContextAttrs(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	var buf bytes.Buffer
+	require.NoError(t, Setup(&buf, "json", "debug"))
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = With(ctx, slog.String(KeyUserID, "u1"), slog.String(KeyMethod, "/a"))
+	ctx = With(ctx, slog.String(KeyMethod, "/b"))
+	slog.InfoContext(ctx, "hello", "extra", 1)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "req-1", line[KeyRequestID])
+	assert.Equal(t, "u1", line[KeyUserID])
+	assert.Equal(t, "/b", line[KeyMethod])
+	assert.Equal(t, float64(1), line["extra"])
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	assert.Error(t, Setup(&bytes.Buffer{}, "xml", "info"))
+	assert.Error(t, Setup(&bytes.Buffer{}, "json", "loud"))
+}
+
+func TestSetLevel(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	var buf bytes.Buffer
+	require.NoError(t, Setup(&buf, "text", "info"))
+	slog.Debug("hidden")
+	require.NoError(t, SetLevel("debug"))
+	slog.Debug("shown")
+	assert.Error(t, SetLevel("loud"))
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestInherit(t *testing.T) {
+	src := WithRequestID(context.Background(), "req-2")
+	dst := Inherit(context.Background(), src)
+
+	assert.Equal(t, "req-2", RequestID(dst))
+	assert.Empty(t, RequestID(context.Background()))
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "api_gateway"
+
+var (
+	// SettingsFetches counts downstream GetSettings calls made after a cache miss
+	SettingsFetches = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "settings",
+		Name:      "fetches_total",
+		Help:      "Downstream GetSettings calls made to fill the cache.",
+	})
+
+	// SettingsCoalesced counts cache misses that joined an in-flight fetch instead of calling downstream
+	SettingsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "settings",
+		Name:      "coalesced_total",
+		Help:      "Cache misses served by a fetch already in flight for the same user.",
+	})
+
+	// SettingsConflicts counts conditional settings updates rejected because the settings changed
+	SettingsConflicts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "settings",
+		Name:      "conflicts_total",
+		Help:      "UpdateUserSettings calls rejected because If-Match did not match the current version.",
+	})
+
+	// ReviewsRateLimited counts AnalyzeReview calls rejected by a rate limit, by the exhausted scope
+	ReviewsRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reviews",
+		Name:      "rate_limited_total",
+		Help:      "AnalyzeReview calls rejected by the user, source or global rate limit.",
+	}, []string{"scope"})
+
+	// RPCDuration observes gRPC handling latency per method
+	RPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "server_handling_seconds",
+		Help:      "Latency of gRPC calls handled by the gateway.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// RPCHandled counts completed gRPC calls per method and status code
+	RPCHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "server_handled_total",
+		Help:      "gRPC calls completed by the gateway, by status code.",
+	}, []string{"method", "code"})
+
+	// CacheLookups counts settings cache lookups by result: hit, stale, expired, miss or error
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "lookups_total",
+		Help:      "Settings cache lookups by result.",
+	}, []string{"result"})
+
+	// CacheSetFailures counts cache writes that failed
+	CacheSetFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "set_failures_total",
+		Help:      "Failed writes of settings to the cache.",
+	})
+
+	// KafkaSendDuration observes how long the broker takes to acknowledge a message
+	KafkaSendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "send_seconds",
+		Help:      "Latency of synchronous Kafka sends.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	// KafkaSendFailures counts messages the producer failed to send
+	KafkaSendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "send_failures_total",
+		Help:      "Kafka sends that failed after producer retries.",
+	}, []string{"topic"})
+
+	// OutboxDeadLettered counts outbox messages moved to the dead-letter stream because they could never be published
+	OutboxDeadLettered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "dead_lettered_total",
+		Help:      "Outbox messages that failed permanently and were moved to the dead-letter stream.",
+	})
+
+	// CustomerDuration observes customer service call latency per client method
+	CustomerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "request_seconds",
+		Help:      "Latency of calls to customer service.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// CustomerErrors counts failed customer service calls per client method and status code
+	CustomerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "errors_total",
+		Help:      "Failed calls to customer service, by status code.",
+	}, []string{"method", "code"})
+
+	// CustomerRetries counts repeated attempts of idempotent customer service calls
+	CustomerRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "retries_total",
+		Help:      "Retried calls to customer service.",
+	}, []string{"method"})
+
+	// CustomerBreakerState is the customer service circuit breaker state: 0 closed, 1 half-open, 2 open
+	CustomerBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "breaker_state",
+		Help:      "Customer service circuit breaker state (0 closed, 1 half-open, 2 open).",
+	})
+
+	// CustomerBreakerTransitions counts circuit breaker state changes by the new state
+	CustomerBreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "breaker_transitions_total",
+		Help:      "Customer service circuit breaker state changes, by new state.",
+	}, []string{"state"})
+
+	// CustomerBreakerRejected counts calls failed fast because the breaker was open
+	CustomerBreakerRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "customer_client",
+		Name:      "breaker_rejected_total",
+		Help:      "Calls to customer service rejected by the open circuit breaker.",
+	}, []string{"method"})
+
+	// IdempotencyRequests counts calls carrying an idempotency key by outcome:
+	// new, replayed, in_flight, mismatch or error (key store unavailable)
+	IdempotencyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "idempotency",
+		Name:      "requests_total",
+		Help:      "Calls made with an idempotency key, by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	// TLSCertificateExpiry is the expiry time of the loaded certificate per TLS identity, as a Unix timestamp
+	TLSCertificateExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "tls",
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "NotAfter of the loaded TLS certificate.",
+	}, []string{"name"})
+
+	// TLSReloadFailures counts failed attempts to reload rotated certificate files
+	TLSReloadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tls",
+		Name:      "reload_failures_total",
+		Help:      "Failed reloads of TLS certificate files.",
+	}, []string{"name"})
+)
@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"api-gateway/internal/logging"
+	"api-gateway/internal/metrics"
+	redisstorage "api-gateway/internal/storage/redis"
+)
+
+var tracer = otel.Tracer("api-gateway/internal/outbox")
+
+const (
+	batchSize    = 100
+	readBlock    = time.Second
+	reclaimEvery = 30 * time.Second
+	// reclaimIdle is how long a message may stay unacknowledged before another relay takes it over
+	reclaimIdle = time.Minute
+	// staleConsumerIdle is how long a consumer without messages may stay silent before it is removed;
+	// a running relay reads at least every readBlock
+	staleConsumerIdle = 10 * time.Minute
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// Publisher sends an event to the message broker (kafka.Producer).
+// Errors that no retry can fix (e.g. a message over the broker's size limit) should have
+// a Permanent() bool method returning true: such messages are dead-lettered instead of retried
+type Publisher interface {
+	SendMessage(ctx context.Context, key string, value interface{}) error
+}
+
+// DeadLetterHandler is told about a message that could never be published, before it is dead-lettered
+type DeadLetterHandler func(ctx context.Context, key string, payload []byte, cause error) error
+
+// Relay moves messages from the outbox to the broker, retrying until each one is delivered.
+// Messages failing with a permanent error are moved to the dead-letter stream
+type Relay struct {
+	source       redisstorage.Outbox
+	publisher    Publisher
+	consumer     string
+	onDeadLetter DeadLetterHandler
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRelay creates a relay; every gateway replica runs one under its own consumer name
+func NewRelay(source redisstorage.Outbox, publisher Publisher) *Relay {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	return &Relay{
+		source:    source,
+		publisher: publisher,
+		consumer:  fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// SetDeadLetterHandler registers fn to be called for every dead-lettered message; call it before Start
+func (r *Relay) SetDeadLetterHandler(fn DeadLetterHandler) {
+	r.onDeadLetter = fn
+}
+
+// Start runs the relay loop in the background until Close is called
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+}
+
+// Close stops the relay after the message in flight is handled
+func (r *Relay) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+func (r *Relay) run(ctx context.Context) {
+	if !r.retry(ctx, "init outbox", func() error { return r.source.Init(ctx) }) {
+		return
+	}
+	// Сначала забираем то, что осталось недоставленным после прошлого запуска
+	lastReclaim := time.Time{}
+	for ctx.Err() == nil {
+		var msgs []redisstorage.OutboxMessage
+		if time.Since(lastReclaim) >= reclaimEvery {
+			lastReclaim = time.Now()
+			if !r.retry(ctx, "reclaim outbox", func() (err error) {
+				msgs, err = r.source.Reclaim(ctx, r.consumer, reclaimIdle, batchSize)
+				return err
+			}) {
+				return
+			}
+			// Consumer с прошлых запусков (hostname-pid) иначе копились бы в группе бесконечно
+			if err := r.source.PruneConsumers(ctx, r.consumer, staleConsumerIdle); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "failed to remove stale outbox consumers", "error", err)
+			}
+		}
+		if len(msgs) == 0 {
+			if !r.retry(ctx, "read outbox", func() (err error) {
+				msgs, err = r.source.Read(ctx, r.consumer, batchSize, readBlock)
+				return err
+			}) {
+				return
+			}
+		}
+		for _, msg := range msgs {
+			if !r.deliver(ctx, msg) {
+				return
+			}
+		}
+	}
+}
+
+// deliver publishes msg and acknowledges it, retrying both steps; a message that can never be
+// published is dead-lettered instead. It reports false once ctx is done
+func (r *Relay) deliver(ctx context.Context, msg redisstorage.OutboxMessage) bool {
+	// Продолжаем трейс запроса, который положил сообщение в outbox
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+	ctx = logging.WithRequestID(ctx, msg.Headers[logging.RequestIDHeader])
+	ctx, span := tracer.Start(ctx, "outbox deliver", trace.WithAttributes(attribute.String("outbox.message_id", msg.ID)))
+	defer span.End()
+
+	var cause error
+	published := r.retry(ctx, "publish "+msg.ID, func() error {
+		err := r.publisher.SendMessage(ctx, msg.Key, json.RawMessage(msg.Payload))
+		if permanent(err) {
+			// Повторы не помогут, а все следующие сообщения ждали бы за этим
+			cause = err
+			return nil
+		}
+		return err
+	})
+	if !published {
+		return false
+	}
+	if cause != nil {
+		return r.deadLetter(ctx, msg, cause)
+	}
+	return r.retry(ctx, "ack "+msg.ID, func() error {
+		return r.source.Ack(context.WithoutCancel(ctx), msg.ID)
+	})
+}
+
+// deadLetter reports msg to the handler and moves it to the dead-letter stream, retrying both steps
+func (r *Relay) deadLetter(ctx context.Context, msg redisstorage.OutboxMessage, cause error) bool {
+	slog.ErrorContext(ctx, "outbox message can never be published, moving it to the dead-letter stream", "message_id", msg.ID, "error", cause)
+	// Сначала помечаем отзыв: если упадем до переноса, сообщение заберет Reclaim и пройдет этот путь еще раз
+	if r.onDeadLetter != nil && !r.retry(ctx, "dead-letter handler "+msg.ID, func() error {
+		return r.onDeadLetter(ctx, msg.Key, msg.Payload, cause)
+	}) {
+		return false
+	}
+	if !r.retry(ctx, "dead-letter "+msg.ID, func() error {
+		return r.source.DeadLetter(context.WithoutCancel(ctx), msg, cause.Error())
+	}) {
+		return false
+	}
+	metrics.OutboxDeadLettered.Inc()
+	return true
+}
+
+// permanent reports whether err says the message can never be published
+func permanent(err error) bool {
+	var p interface{ Permanent() bool }
+	return errors.As(err, &p) && p.Permanent()
+}
+
+// retry calls fn with exponential backoff until it succeeds or ctx is done
+func (r *Relay) retry(ctx context.Context, op string, fn func() error) bool {
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		slog.WarnContext(ctx, "outbox operation failed, retrying", "op", op, "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
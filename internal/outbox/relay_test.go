@@ -0,0 +1,247 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	redisstorage "api-gateway/internal/storage/redis"
+)
+
+// fakeOutbox hands out queued messages once and records acknowledgements
+type fakeOutbox struct {
+	mu     sync.Mutex
+	queued []redisstorage.OutboxMessage
+	acked  []string
+	dead   map[string]string
+	pruned []string
+}
+
+func (f *fakeOutbox) SendMessage(ctx context.Context, key string, value interface{}) error {
+	return nil
+}
+
+func (f *fakeOutbox) Init(ctx context.Context) error { return nil }
+
+func (f *fakeOutbox) Read(ctx context.Context, consumer string, count int64, block time.Duration) ([]redisstorage.OutboxMessage, error) {
+	f.mu.Lock()
+	msgs := f.queued
+	f.queued = nil
+	f.mu.Unlock()
+	if len(msgs) == 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return msgs, nil
+}
+
+func (f *fakeOutbox) Reclaim(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]redisstorage.OutboxMessage, error) {
+	return nil, nil
+}
+
+func (f *fakeOutbox) Ack(ctx context.Context, ids ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.acked = append(f.acked, ids...)
+	return nil
+}
+
+func (f *fakeOutbox) DeadLetter(ctx context.Context, msg redisstorage.OutboxMessage, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dead == nil {
+		f.dead = map[string]string{}
+	}
+	f.dead[msg.ID] = reason
+	return nil
+}
+
+func (f *fakeOutbox) PruneConsumers(ctx context.Context, keep string, idle time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pruned = append(f.pruned, keep)
+	return nil
+}
+
+func (f *fakeOutbox) deadLettered() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]string, len(f.dead))
+	for id, reason := range f.dead {
+		out[id] = reason
+	}
+	return out
+}
+
+func (f *fakeOutbox) ackedIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.acked...)
+}
+
+// tooLargeError is a publish error no retry can fix
+type tooLargeError struct{}
+
+func (tooLargeError) Error() string   { return "message too large" }
+func (tooLargeError) Permanent() bool { return true }
+
+// flakyPublisher fails the first `failures` sends and every send of a key in `rejected`
+type flakyPublisher struct {
+	mu       sync.Mutex
+	failures int
+	rejected map[string]bool
+	attempts int
+	sent     []string
+	traceIDs []string
+}
+
+func (p *flakyPublisher) SendMessage(ctx context.Context, key string, value interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if p.rejected[key] {
+		return fmt.Errorf("kafka send error: %w", tooLargeError{})
+	}
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("kafka unavailable")
+	}
+	b, _ := json.Marshal(value)
+	p.sent = append(p.sent, key+":"+string(b))
+	p.traceIDs = append(p.traceIDs, trace.SpanContextFromContext(ctx).TraceID().String())
+	return nil
+}
+
+func (p *flakyPublisher) attempted() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.attempts
+}
+
+func (p *flakyPublisher) sentMessages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.sent...)
+}
+
+func TestRelay_RetriesUntilDelivered(t *testing.T) {
+	source := &fakeOutbox{queued: []redisstorage.OutboxMessage{
+		{ID: "1-0", Key: "u1", Payload: []byte(`{"review_id":"r1"}`)},
+	}}
+	publisher := &flakyPublisher{failures: 2}
+
+	relay := NewRelay(source, publisher)
+	relay.Start()
+	defer relay.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(source.ackedIDs()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []string{"1-0"}, source.ackedIDs())
+	assert.Equal(t, []string{`u1:{"review_id":"r1"}`}, publisher.sentMessages())
+}
+
+func TestRelay_DoesNotAckWhileBrokerDown(t *testing.T) {
+	source := &fakeOutbox{queued: []redisstorage.OutboxMessage{
+		{ID: "1-0", Key: "u1", Payload: []byte(`{}`)},
+	}}
+	publisher := &flakyPublisher{failures: 1000}
+
+	relay := NewRelay(source, publisher)
+	relay.Start()
+	// Дожидаемся повторной отправки: первая неудача не должна привести к подтверждению
+	assert.Eventually(t, func() bool {
+		return publisher.attempted() >= 2
+	}, 2*time.Second, 10*time.Millisecond)
+	relay.Close()
+
+	assert.Empty(t, source.ackedIDs())
+	assert.Empty(t, publisher.sentMessages())
+}
+
+func TestRelay_DeadLettersPermanentFailures(t *testing.T) {
+	source := &fakeOutbox{queued: []redisstorage.OutboxMessage{
+		{ID: "1-0", Key: "huge", Payload: []byte(`{"review_id":"r1"}`)},
+		{ID: "2-0", Key: "u1", Payload: []byte(`{"review_id":"r2"}`)},
+	}}
+	publisher := &flakyPublisher{rejected: map[string]bool{"huge": true}}
+
+	var mu sync.Mutex
+	var failed []string
+	relay := NewRelay(source, publisher)
+	relay.SetDeadLetterHandler(func(ctx context.Context, key string, payload []byte, cause error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, key+":"+string(payload))
+		return nil
+	})
+	relay.Start()
+	defer relay.Close()
+
+	// Непубликуемое сообщение не задерживает следующее
+	assert.Eventually(t, func() bool {
+		return len(source.ackedIDs()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"2-0"}, source.ackedIDs())
+	assert.Equal(t, map[string]string{"1-0": "kafka send error: message too large"}, source.deadLettered())
+	assert.Equal(t, 2, publisher.attempted(), "permanent failures are not retried")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{`huge:{"review_id":"r1"}`}, failed)
+}
+
+func TestRelay_PrunesStaleConsumers(t *testing.T) {
+	source := &fakeOutbox{}
+	relay := NewRelay(source, &flakyPublisher{})
+	relay.Start()
+	defer relay.Close()
+
+	assert.Eventually(t, func() bool {
+		source.mu.Lock()
+		defer source.mu.Unlock()
+		return len(source.pruned) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	assert.Equal(t, relay.consumer, source.pruned[0], "the relay keeps its own consumer")
+}
+
+func TestRelay_ContinuesTrace(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	source := &fakeOutbox{queued: []redisstorage.OutboxMessage{{
+		ID:      "1-0",
+		Key:     "u1",
+		Payload: []byte(`{}`),
+		Headers: map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"},
+	}}}
+	publisher := &flakyPublisher{}
+
+	relay := NewRelay(source, publisher)
+	relay.Start()
+	defer relay.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(source.ackedIDs()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	assert.Equal(t, []string{traceID}, publisher.traceIDs)
+}
@@ -0,0 +1,60 @@
+// Package limit defines rate limits and their textual form. It has no dependencies,
+// so that configuration can be validated without the limiter behind it
+package limit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period with bursts up to Requests. The zero Limit is unlimited
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether the limit is disabled
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Parse parses "<requests>/<period>", e.g. "100/1m" or "5/s".
+// An empty string or "0" means unlimited. Periods are counted in milliseconds, so shorter ones are rejected
+func Parse(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	n, p, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: want <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid request count", s)
+	}
+	// "5/s" reads better than "5/1s"
+	if p != "" && (p[0] < '0' || p[0] > '9') {
+		p = "1" + p
+	}
+	period, err := time.ParseDuration(p)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid period", s)
+	}
+	if period < time.Millisecond {
+		return Limit{}, fmt.Errorf("rate limit %q: period must be at least 1ms", s)
+	}
+	if requests == 0 {
+		return Limit{}, nil
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
@@ -0,0 +1,30 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]Limit{
+		"":       {},
+		"0":      {},
+		"0/1m":   {},
+		"100/1m": {Requests: 100, Period: time.Minute},
+		"5/s":    {Requests: 5, Period: time.Second},
+		" 3/2h ": {Requests: 3, Period: 2 * time.Hour},
+		"1/1ms":  {Requests: 1, Period: time.Millisecond},
+	}
+	for in, want := range cases {
+		got, err := Parse(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"100", "x/1m", "-1/1m", "10/0s", "10/forever", "100/500us"} {
+		_, err := Parse(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"api-gateway/internal/metrics"
+	"api-gateway/internal/ratelimit/limit"
+	redisstorage "api-gateway/internal/storage/redis"
+)
+
+const (
+	// RetryAfterHeader carries the number of seconds to wait after a ResourceExhausted response
+	RetryAfterHeader = "retry-after"
+
+	keyPrefix = "ratelimit:reviews:"
+)
+
+// Config holds the AnalyzeReview limits
+type Config struct {
+	User   limit.Limit
+	Source limit.Limit
+	Global limit.Limit
+	// Sources overrides Source for individual review sources
+	Sources map[string]limit.Limit
+}
+
+// ParseConfig builds a Config from its textual form; overrides map source names to limits
+func ParseConfig(user, source, global string, overrides map[string]string) (Config, error) {
+	var (
+		cfg Config
+		err error
+	)
+	if cfg.User, err = limit.Parse(user); err != nil {
+		return Config{}, fmt.Errorf("user: %w", err)
+	}
+	if cfg.Source, err = limit.Parse(source); err != nil {
+		return Config{}, fmt.Errorf("source: %w", err)
+	}
+	if cfg.Global, err = limit.Parse(global); err != nil {
+		return Config{}, fmt.Errorf("global: %w", err)
+	}
+	cfg.Sources = make(map[string]limit.Limit, len(overrides))
+	for name, spec := range overrides {
+		if cfg.Sources[name], err = limit.Parse(spec); err != nil {
+			return Config{}, fmt.Errorf("source %s: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether any limit is set
+func (c Config) Enabled() bool {
+	if !c.User.Unlimited() || !c.Source.Unlimited() || !c.Global.Unlimited() {
+		return true
+	}
+	for _, l := range c.Sources {
+		if !l.Unlimited() {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceLimit returns the override for source, if any, or the default source limit
+func (c Config) sourceLimit(source string) limit.Limit {
+	if l, ok := c.Sources[source]; ok {
+		return l
+	}
+	return c.Source
+}
+
+// Limiter applies Config to AnalyzeReview calls using buckets shared across replicas
+type Limiter struct {
+	store redisstorage.RateLimiter
+	cfg   atomic.Pointer[Config]
+}
+
+// New creates a review limiter
+func New(store redisstorage.RateLimiter, cfg Config) *Limiter {
+	l := &Limiter{store: store}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig replaces the limits for subsequent calls. Buckets keep their tokens, capped
+// at the new capacity, and refill at the new rate
+func (l *Limiter) SetConfig(cfg Config) {
+	l.cfg.Store(&cfg)
+}
+
+// AllowReview takes a token from the user, source and global buckets. When one is
+// empty it returns ResourceExhausted with RetryInfo and sets the retry-after header.
+// Limiter failures let the request through: the outbox write will fail anyway if
+// Redis is really gone, and a broken limiter must not block every review
+func (l *Limiter) AllowReview(ctx context.Context, userID, source string) error {
+	var (
+		buckets []redisstorage.Bucket
+		scopes  []string
+	)
+	add := func(scope, key string, lim limit.Limit) {
+		if lim.Unlimited() {
+			return
+		}
+		buckets = append(buckets, redisstorage.Bucket{Key: keyPrefix + key, Capacity: lim.Requests, Period: lim.Period})
+		scopes = append(scopes, scope)
+	}
+	cfg := l.cfg.Load()
+	add("user", "user:"+userID, cfg.User)
+	add("source", "source:"+source, cfg.sourceLimit(source))
+	add("global", "global", cfg.Global)
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	res, err := l.store.Take(ctx, buckets...)
+	if err != nil {
+		slog.WarnContext(ctx, "rate limiter unavailable, allowing review", "error", err)
+		return nil
+	}
+	if res.Allowed {
+		return nil
+	}
+
+	scope := scopes[res.Exhausted]
+	metrics.ReviewsRateLimited.WithLabelValues(scope).Inc()
+
+	seconds := int64(math.Ceil(res.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	// Вне gRPC-запроса заголовок выставить нельзя - клиент все равно получит RetryInfo
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, strconv.FormatInt(seconds, 10)))
+	st, err := status.New(codes.ResourceExhausted, fmt.Sprintf("%s rate limit exceeded for AnalyzeReview", scope)).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+	if err != nil {
+		return status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded for AnalyzeReview", scope)
+	}
+	return st.Err()
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/internal/ratelimit/limit"
+	redisstorage "api-gateway/internal/storage/redis"
+)
+
+// fakeStore records the buckets it was asked for and returns a canned result
+type fakeStore struct {
+	buckets []redisstorage.Bucket
+	result  redisstorage.RateLimitResult
+	err     error
+}
+
+func (f *fakeStore) Take(ctx context.Context, buckets ...redisstorage.Bucket) (redisstorage.RateLimitResult, error) {
+	f.buckets = buckets
+	return f.result, f.err
+}
+
+func TestParseConfig_Overrides(t *testing.T) {
+	cfg, err := ParseConfig("10/1m", "100/1m", "", map[string]string{"partner": "5/1m", "internal": "0"})
+	require.NoError(t, err)
+
+	assert.True(t, cfg.Enabled())
+	assert.True(t, cfg.Global.Unlimited())
+	assert.Equal(t, limit.Limit{Requests: 5, Period: time.Minute}, cfg.sourceLimit("partner"))
+	assert.True(t, cfg.sourceLimit("internal").Unlimited())
+	assert.Equal(t, cfg.Source, cfg.sourceLimit("web"))
+
+	_, err = ParseConfig("10/1m", "", "", map[string]string{"partner": "lots"})
+	assert.Error(t, err)
+}
+
+func TestAllowReview_Buckets(t *testing.T) {
+	store := &fakeStore{result: redisstorage.RateLimitResult{Allowed: true}}
+	l := New(store, Config{
+		User:    limit.Limit{Requests: 10, Period: time.Minute},
+		Source:  limit.Limit{Requests: 100, Period: time.Minute},
+		Global:  limit.Limit{Requests: 1000, Period: time.Minute},
+		Sources: map[string]limit.Limit{"partner": {Requests: 5, Period: time.Second}},
+	})
+
+	require.NoError(t, l.AllowReview(context.Background(), "u1", "partner"))
+	assert.Equal(t, []redisstorage.Bucket{
+		{Key: "ratelimit:reviews:user:u1", Capacity: 10, Period: time.Minute},
+		{Key: "ratelimit:reviews:source:partner", Capacity: 5, Period: time.Second},
+		{Key: "ratelimit:reviews:global", Capacity: 1000, Period: time.Minute},
+	}, store.buckets)
+}
+
+func TestAllowReview_SetConfig(t *testing.T) {
+	store := &fakeStore{result: redisstorage.RateLimitResult{Allowed: true}}
+	l := New(store, Config{})
+
+	require.NoError(t, l.AllowReview(context.Background(), "u1", "web"))
+	assert.Empty(t, store.buckets, "no limits, no buckets")
+
+	l.SetConfig(Config{User: limit.Limit{Requests: 10, Period: time.Minute}})
+	require.NoError(t, l.AllowReview(context.Background(), "u1", "web"))
+	assert.Equal(t, []redisstorage.Bucket{
+		{Key: "ratelimit:reviews:user:u1", Capacity: 10, Period: time.Minute},
+	}, store.buckets)
+}
+
+func TestAllowReview_Exhausted(t *testing.T) {
+	store := &fakeStore{result: redisstorage.RateLimitResult{Exhausted: 1, RetryAfter: 1500 * time.Millisecond}}
+	l := New(store, Config{
+		User:   limit.Limit{Requests: 10, Period: time.Minute},
+		Source: limit.Limit{Requests: 100, Period: time.Minute},
+	})
+
+	err := l.AllowReview(context.Background(), "u1", "web")
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Contains(t, st.Message(), "source")
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, info.RetryDelay.AsDuration())
+}
+
+func TestAllowReview_StoreErrorFailsOpen(t *testing.T) {
+	store := &fakeStore{err: errors.New("redis: connection refused")}
+	l := New(store, Config{User: limit.Limit{Requests: 1, Period: time.Minute}})
+
+	assert.NoError(t, l.AllowReview(context.Background(), "u1", "web"))
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Dependencies named in errors returned to callers
+const (
+	depCustomer = "customer service"
+	depQueue    = "review queue"
+	depReviews  = "review store"
+	depLock     = "settings lock"
+)
+
+// dependencyError converts an error from Redis, Kafka or customer service into a gRPC status.
+// Statuses (e.g. from customer service) pass through, deadlines become DeadlineExceeded,
+// cancellation stays Canceled, and anything else means the dependency is Unavailable.
+// Details of infrastructure errors stay in the logs
+func dependencyError(dependency string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "%s did not respond in time", dependency)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	default:
+		return status.Errorf(codes.Unavailable, "%s is temporarily unavailable", dependency)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"api-gateway/internal/validation"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// UpdateMaskHeader carries the google.protobuf.FieldMask of an UpdateUserSettings call as
+// comma-separated paths; UpdateUserSettingsRequest has no field for it. Without a mask
+// (or with an empty one) the request replaces all settings
+const UpdateMaskHeader = "x-update-mask"
+
+// maskableSettings are the UpdateUserSettingsRequest fields an update mask may name
+var maskableSettings = []protoreflect.Name{"theme", "picked_model", "font"}
+
+// updateMask reads the update mask of the call; nil means no mask was sent
+func updateMask(ctx context.Context) (*fieldmaskpb.FieldMask, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var paths []string
+	for _, v := range md.Get(UpdateMaskHeader) {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, p := range paths {
+		if !isMaskable(p) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       "update_mask",
+				Description: fmt.Sprintf("unknown path %q, want one of %s", p, joinNames(maskableSettings)),
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return nil, validation.Invalid(violations...)
+	}
+	mask := &fieldmaskpb.FieldMask{Paths: paths}
+	mask.Normalize()
+	return mask, nil
+}
+
+// mergeSettings returns the full update: current settings with the masked fields taken from req
+func mergeSettings(current *pb.GetUserSettingsResponse, req *pb.UpdateUserSettingsRequest, mask *fieldmaskpb.FieldMask) *pb.UpdateUserSettingsRequest {
+	merged := &pb.UpdateUserSettingsRequest{UserId: req.GetUserId()}
+	dst := merged.ProtoReflect()
+	src := current.ProtoReflect()
+	for _, name := range maskableSettings {
+		// Поля настроек называются одинаково в ответе и в запросе обновления
+		dst.Set(dst.Descriptor().Fields().ByName(name), src.Get(src.Descriptor().Fields().ByName(name)))
+	}
+	from := req.ProtoReflect()
+	for _, p := range mask.GetPaths() {
+		fd := dst.Descriptor().Fields().ByName(protoreflect.Name(p))
+		dst.Set(fd, from.Get(fd))
+	}
+	return merged
+}
+
+func isMaskable(path string) bool {
+	for _, name := range maskableSettings {
+		if string(name) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func joinNames(names []protoreflect.Name) string {
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = string(n)
+	}
+	return strings.Join(parts, ", ")
+}
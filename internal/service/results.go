@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	reviewpb "api-gateway/api/review"
+	"api-gateway/internal/logging"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ReviewResult - то, что process-service пишет в топик с результатами анализа
+type ReviewResult struct {
+	ReviewID    string          `json:"review_id"`
+	UserID      string          `json:"user_id"`
+	Status      string          `json:"status"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ProcessedAt time.Time       `json:"processed_at"`
+}
+
+// HandleReviewResult decodes a message from the results topic and applies it.
+// Malformed messages are logged and dropped so they don't block the partition
+func (s *Service) HandleReviewResult(ctx context.Context, key string, value []byte) error {
+	var res ReviewResult
+	if err := json.Unmarshal(value, &res); err != nil {
+		slog.WarnContext(ctx, "dropping malformed review result", "key", key, "error", err)
+		return nil
+	}
+	if res.ReviewID == "" {
+		res.ReviewID = key
+	}
+	if res.ReviewID == "" {
+		slog.WarnContext(ctx, "dropping review result without review_id")
+		return nil
+	}
+	ctx = logging.With(ctx, slog.String(logging.KeyReviewID, res.ReviewID))
+	if res.UserID != "" {
+		ctx = logging.With(ctx, slog.String(logging.KeyUserID, res.UserID))
+	}
+	return s.ApplyReviewResult(ctx, &res)
+}
+
+// ApplyReviewResult moves a review to the state reported by process-service.
+// Terminal states (DONE, FAILED) are never overwritten by late PROCESSING updates
+func (s *Service) ApplyReviewResult(ctx context.Context, res *ReviewResult) error {
+	next := res.Status
+	if next == "" {
+		next = ReviewStatusDone
+		if res.Error != "" {
+			next = ReviewStatusFailed
+		}
+	}
+	if !isKnownReviewStatus(next) {
+		slog.WarnContext(ctx, "dropping review result with unknown status", "status", next)
+		return nil
+	}
+
+	current, err := s.reviews.GetReview(ctx, res.ReviewID)
+	if err != nil {
+		return fmt.Errorf("load review %s: %w", res.ReviewID, err)
+	}
+	if current != nil && isTerminalReviewStatus(current.Status) && !isTerminalReviewStatus(next) {
+		slog.InfoContext(ctx, "ignoring stale review update", "status", next, "current_status", current.Status)
+		return nil
+	}
+
+	review := &reviewpb.ReviewStatus{
+		ReviewId:  res.ReviewID,
+		UserId:    res.UserID,
+		Status:    next,
+		Error:     res.Error,
+		UpdatedAt: timestamppb.Now(),
+	}
+	if !res.ProcessedAt.IsZero() {
+		review.UpdatedAt = timestamppb.New(res.ProcessedAt)
+	}
+	if review.UserId == "" && current != nil {
+		review.UserId = current.UserId
+	}
+	if len(res.Result) > 0 && string(res.Result) != "null" {
+		result := &structpb.Struct{}
+		if err := result.UnmarshalJSON(res.Result); err != nil {
+			slog.WarnContext(ctx, "dropping non-object review result", "error", err)
+		} else {
+			review.Result = result
+		}
+	}
+
+	if err := s.reviews.SetReview(ctx, review); err != nil {
+		return fmt.Errorf("save review %s: %w", res.ReviewID, err)
+	}
+	return nil
+}
+
+// FailUndeliveredReview marks the review carried by an outbox message that could never be
+// published as FAILED, so that its client stops waiting. It is an outbox.DeadLetterHandler
+func (s *Service) FailUndeliveredReview(ctx context.Context, key string, payload []byte, cause error) error {
+	var p ReviewPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.ReviewID == "" {
+		slog.WarnContext(ctx, "dead-lettered outbox message carries no review", "key", key, "error", err)
+		return nil
+	}
+	ctx = logging.With(ctx, slog.String(logging.KeyReviewID, p.ReviewID))
+	// Причину оставляем в логах, клиенту она ничего не скажет
+	slog.WarnContext(ctx, "review could not be published for analysis", "error", cause)
+	return s.ApplyReviewResult(ctx, &ReviewResult{
+		ReviewID: p.ReviewID,
+		UserID:   p.UserID,
+		Status:   ReviewStatusFailed,
+		Error:    "review could not be queued for analysis",
+	})
+}
+
+func isKnownReviewStatus(status string) bool {
+	switch status {
+	case ReviewStatusQueued, ReviewStatusProcessing, ReviewStatusDone, ReviewStatusFailed:
+		return true
+	}
+	return false
+}
+
+// reviewStatusRank orders states along the lifecycle
+func reviewStatusRank(status string) int {
+	switch status {
+	case ReviewStatusQueued:
+		return 1
+	case ReviewStatusProcessing:
+		return 2
+	case ReviewStatusDone, ReviewStatusFailed:
+		return 3
+	}
+	return 0
+}
+
+func isTerminalReviewStatus(status string) bool {
+	return status == ReviewStatusDone || status == ReviewStatusFailed
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	reviewpb "api-gateway/api/review"
+)
+
+// TestHandleReviewResult_Done tests that a finished analysis is stored with its result
+func TestHandleReviewResult_Done(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").
+		Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", UserId: "u1", Status: ReviewStatusQueued}, nil)
+	mockReviews.On("SetReview", mock.Anything, mock.MatchedBy(func(r *reviewpb.ReviewStatus) bool {
+		return r.ReviewId == "uuid-1" &&
+			r.UserId == "u1" &&
+			r.Status == ReviewStatusDone &&
+			r.Result.GetFields()["sentiment"].GetStringValue() == "positive"
+	})).Return(nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	err := svc.HandleReviewResult(context.Background(), "uuid-1", []byte(`{"status":"DONE","result":{"sentiment":"positive"}}`))
+
+	assert.NoError(t, err)
+	mockReviews.AssertExpectations(t)
+}
+
+// TestHandleReviewResult_Malformed tests that undecodable messages are skipped
+func TestHandleReviewResult_Malformed(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	err := svc.HandleReviewResult(context.Background(), "uuid-1", []byte(`not json`))
+
+	assert.NoError(t, err)
+	mockReviews.AssertNotCalled(t, "SetReview")
+}
+
+// TestHandleReviewResult_StaleProcessing tests that a late PROCESSING doesn't undo DONE
+func TestHandleReviewResult_StaleProcessing(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").
+		Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusDone}, nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	err := svc.HandleReviewResult(context.Background(), "uuid-1", []byte(`{"review_id":"uuid-1","status":"PROCESSING"}`))
+
+	assert.NoError(t, err)
+	mockReviews.AssertNotCalled(t, "SetReview")
+}
+
+// TestHandleReviewResult_StoreError tests that storage failures are returned for redelivery
+func TestHandleReviewResult_StoreError(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").Return(nil, errors.New("redis down"))
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	err := svc.HandleReviewResult(context.Background(), "uuid-1", []byte(`{"status":"FAILED","error":"model timeout"}`))
+
+	assert.Error(t, err)
+}
+
+// TestFailUndeliveredReview tests that a review which can never reach Kafka is marked FAILED
+func TestFailUndeliveredReview(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").
+		Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", UserId: "u1", Status: ReviewStatusQueued}, nil)
+	mockReviews.On("SetReview", mock.Anything, mock.MatchedBy(func(r *reviewpb.ReviewStatus) bool {
+		return r.ReviewId == "uuid-1" && r.UserId == "u1" && r.Status == ReviewStatusFailed && r.Error != ""
+	})).Return(nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	err := svc.FailUndeliveredReview(context.Background(), "u1", []byte(`{"review_id":"uuid-1","user_id":"u1","text":"..."}`), errors.New("message too large"))
+	assert.NoError(t, err)
+	mockReviews.AssertExpectations(t)
+
+	// Сообщение без отзыва пропускаем
+	assert.NoError(t, svc.FailUndeliveredReview(context.Background(), "u1", []byte(`not json`), errors.New("message too large")))
+}
@@ -2,18 +2,61 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	reviewpb "api-gateway/api/review"
+	"api-gateway/internal/logging"
+	"api-gateway/internal/metrics"
 	redisstorage "api-gateway/internal/storage/redis"
+	"api-gateway/internal/validation"
 
 	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// EventProducer интерфейс, чтобы не зависеть от kafka напрямую (для тестов удобно)
+const (
+	// settingsFetchTimeout bounds a shared downstream GetSettings call
+	settingsFetchTimeout = 5 * time.Second
+	// settingsLockTTL bounds how long an update holds the user's settings lock, should its replica die;
+	// it covers the downstream write with retries
+	settingsLockTTL = 15 * time.Second
+	// settingsLockWait is how long an update waits for a concurrent update of the same user
+	settingsLockWait = 2 * time.Second
+	// StaleHeader is set in response metadata when settings are served from an expired cache entry
+	StaleHeader = "x-cache-stale"
+)
+
+// How UpdateSettings keeps the settings cache current, see SetCacheUpdateMode
+const (
+	// CacheInvalidate deletes the cached settings; the next read fetches them from customer service
+	CacheInvalidate = "invalidate"
+	// CacheWriteThrough caches the settings returned by the update, guarded by their updated_at
+	CacheWriteThrough = "write-through"
+)
+
+// Review lifecycle states
+const (
+	ReviewStatusQueued     = "QUEUED"
+	ReviewStatusProcessing = "PROCESSING"
+	ReviewStatusDone       = "DONE"
+	ReviewStatusFailed     = "FAILED"
+)
+
+// EventProducer интерфейс, чтобы не зависеть от kafka напрямую (для тестов удобно).
+// В проде сюда передается outbox, а не сам kafka.Producer
 type EventProducer interface {
-	SendMessage(key string, value interface{}) error
+	SendMessage(ctx context.Context, key string, value interface{}) error
 }
 
 // ReviewPayload - то, что улетит в Кафку (должно совпадать с тем, что ждет process-service)
@@ -32,70 +75,313 @@ type CustomerServiceClient interface {
 	CreateUserProfile(ctx context.Context, req *pb.CreateUserProfileRequest) (*pb.CreateUserProfileResponse, error)
 }
 
+// ReviewLimiter rejects AnalyzeReview calls over the configured rate limits
+type ReviewLimiter interface {
+	AllowReview(ctx context.Context, userID, source string) error
+}
+
 // Service provides business logic for the API gateway
 type Service struct {
 	store    redisstorage.Storage
 	client   CustomerServiceClient
 	producer EventProducer
+	reviews  redisstorage.ReviewStore
+	// limiter is optional; nil disables rate limiting
+	limiter ReviewLimiter
+
+	// writeThrough caches the result of UpdateSettings instead of invalidating it
+	writeThrough atomic.Bool
+	// locker serializes updates of one user's settings; nil leaves them unserialized
+	locker redisstorage.Locker
+
+	// settingsFlight coalesces concurrent cache misses per user ID
+	settingsFlight singleflight.Group
 }
 
 // New creates a new service
-func New(store redisstorage.Storage, client CustomerServiceClient, producer EventProducer) *Service {
+func New(store redisstorage.Storage, client CustomerServiceClient, producer EventProducer, reviews redisstorage.ReviewStore, limiter ReviewLimiter) *Service {
 	return &Service{
 		store:    store,
 		client:   client,
 		producer: producer,
+		reviews:  reviews,
+		limiter:  limiter,
 	}
 }
 
-// GetSettings implements cache-aside: check cache, otherwise fetch from customer and store in background
+// SetCacheUpdateMode selects CacheInvalidate (the default) or CacheWriteThrough
+func (s *Service) SetCacheUpdateMode(mode string) {
+	s.writeThrough.Store(mode == CacheWriteThrough)
+}
+
+// SetSettingsLocker makes UpdateSettings hold a per-user lock from reading the current
+// settings (If-Match, update masks) until the downstream write is done
+func (s *Service) SetSettingsLocker(locker redisstorage.Locker) {
+	s.locker = locker
+}
+
+// GetSettings implements cache-aside: check cache, otherwise fetch from customer and store in background.
+// Soft-expired entries are served while revalidated; expired entries are served only if customer service fails.
+// The version of the returned settings is sent in ETagHeader
 func (s *Service) GetSettings(ctx context.Context, req *pb.GetUserSettingsRequest) (*pb.GetUserSettingsResponse, error) {
-	if req == nil || req.UserId == "" {
-		return nil, nil
+	if err := validation.Validate(req); err != nil {
+		return nil, err
 	}
 	// Try cache
 	cached, err := s.store.Get(ctx, req.UserId)
-	if err != nil {
-		log.Printf("redis get error: %v", err)
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "settings cache read failed", "error", err)
+		metrics.CacheLookups.WithLabelValues("error").Inc()
+	case cached == nil:
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
 	}
 	if cached != nil {
-		return cached, nil
+		switch cached.Freshness {
+		case redisstorage.Fresh:
+			metrics.CacheLookups.WithLabelValues("hit").Inc()
+			setETag(ctx, cached.Settings.GetUpdatedAt())
+			return cached.Settings, nil
+		case redisstorage.Stale:
+			// stale-while-revalidate: отдаем сразу, обновляем в фоне
+			metrics.CacheLookups.WithLabelValues("stale").Inc()
+			s.refreshSettings(ctx, req.UserId)
+			setETag(ctx, cached.Settings.GetUpdatedAt())
+			return cached.Settings, nil
+		default:
+			metrics.CacheLookups.WithLabelValues("expired").Inc()
+		}
+	}
+
+	// Not in cache (or expired) - call customer service once for all concurrent misses of this user
+	resp, err := s.loadSettings(ctx, req.UserId)
+	if err != nil {
+		if cached != nil && ctx.Err() == nil {
+			// stale-if-error: лучше устаревшие настройки, чем ошибка
+			slog.WarnContext(ctx, "serving expired settings after downstream error", "error", err)
+			markStale(ctx)
+			setETag(ctx, cached.Settings.GetUpdatedAt())
+			return cached.Settings, nil
+		}
+		return nil, dependencyError(depCustomer, err)
+	}
+	setETag(ctx, resp.GetUpdatedAt())
+	return resp, nil
+}
+
+// loadSettings waits for a shared downstream fetch of userID's settings
+func (s *Service) loadSettings(ctx context.Context, userID string) (*pb.GetUserSettingsResponse, error) {
+	leader := false
+	ch := s.settingsFlight.DoChan(userID, func() (interface{}, error) {
+		leader = true
+		return s.fetchSettings(ctx, userID)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		resp := res.Val.(*pb.GetUserSettingsResponse)
+		if !leader {
+			metrics.SettingsCoalesced.Inc()
+		}
+		if res.Shared {
+			// Каждый ждущий получает свою копию, чтобы ответы не делили память
+			resp = proto.Clone(resp).(*pb.GetUserSettingsResponse)
+		}
+		return resp, nil
 	}
+}
+
+// refreshSettings starts a background fetch unless one is already in flight for userID
+func (s *Service) refreshSettings(ctx context.Context, userID string) {
+	s.settingsFlight.DoChan(userID, func() (interface{}, error) {
+		resp, err := s.fetchSettings(ctx, userID)
+		if err != nil {
+			slog.WarnContext(ctx, "background settings refresh failed", "error", err)
+		}
+		return resp, err
+	})
+}
 
-	// Not in cache - call customer service
-	resp, err := s.client.GetSettings(ctx, req)
+// markStale tells the caller that the response came from an expired cache entry
+func markStale(ctx context.Context) {
+	// Вне gRPC-запроса (например, в тестах) заголовок выставить нельзя - это не ошибка
+	_ = grpc.SetHeader(ctx, metadata.Pairs(StaleHeader, "true"))
+}
+
+// fetchSettings loads settings from customer service and caches them in background.
+// It runs detached from any single caller, so one cancelled request doesn't fail the others;
+// only the trace of the request that started it is kept
+func (s *Service) fetchSettings(parent context.Context, userID string) (*pb.GetUserSettingsResponse, error) {
+	ctx, cancel := context.WithTimeout(detach(parent), settingsFetchTimeout)
+	defer cancel()
+
+	metrics.SettingsFetches.Inc()
+	resp, err := s.client.GetSettings(ctx, &pb.GetUserSettingsRequest{UserId: userID})
 	if err != nil {
 		return nil, err
 	}
 	// Save to redis in background
 	go func(r *pb.GetUserSettingsResponse, userID string) {
-		if err := s.store.Set(context.Background(), userID, r); err != nil {
-			log.Printf("failed to set cache for user %s: %v", userID, err)
+		ctx := detach(ctx)
+		// ErrNewerCached: пока мы читали, настройки обновили и уже закэшировали - так и должно быть
+		if err := s.store.Set(ctx, userID, r); err != nil && !errors.Is(err, redisstorage.ErrNewerCached) {
+			metrics.CacheSetFailures.Inc()
+			slog.WarnContext(ctx, "settings cache write failed", "error", err)
 		}
-	}(resp, req.UserId)
+	}(resp, userID)
 
 	return resp, nil
 }
 
-// UpdateSettings - call downstream and invalidate cache
+// detach returns a background context carrying only the span and log attributes of ctx
+func detach(ctx context.Context) context.Context {
+	return logging.Inherit(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)), ctx)
+}
+
+// UpdateSettings - call downstream and invalidate cache.
+// With an update mask (see UpdateMaskHeader) only the masked fields change: the others are
+// filled from the current settings and the full result is forwarded.
+// With If-Match (see IfMatchHeader) the update is rejected with FailedPrecondition, carrying
+// the current settings, when they changed since the client read them. Customer service has
+// no conditional update, so the check and the write run under a per-user lock (see SetSettingsLocker)
 func (s *Service) UpdateSettings(ctx context.Context, req *pb.UpdateUserSettingsRequest) (*pb.UpdateUserSettingsResponse, error) {
-	if req == nil || req.UserId == "" {
-		return nil, nil
+	if err := validation.Validate(req); err != nil {
+		return nil, err
 	}
-	resp, err := s.client.UpdateSettings(ctx, req)
+	mask, err := updateMask(ctx)
+	if err != nil {
+		return nil, err
+	}
+	etags, err := ifMatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := s.lockSettings(ctx, req.UserId, etags != nil)
 	if err != nil {
 		return nil, err
 	}
-	if err := s.store.Invalidate(ctx, req.UserId); err != nil {
-		log.Printf("failed to invalidate cache for user %s: %v", req.UserId, err)
+	defer unlock()
+
+	var current *pb.GetUserSettingsResponse
+	switch {
+	case etags != nil:
+		// Версию сверяем с customer service: кэш может отставать от чужой записи
+		current, err = s.client.GetSettings(ctx, &pb.GetUserSettingsRequest{UserId: req.UserId})
+		if err != nil {
+			return nil, dependencyError(depCustomer, err)
+		}
+		if err := checkVersion(ctx, req.UserId, current, etags); err != nil {
+			metrics.SettingsConflicts.Inc()
+			return nil, err
+		}
+	case mask != nil:
+		current, err = s.currentSettings(ctx, req.UserId)
+		if err != nil {
+			return nil, dependencyError(depCustomer, err)
+		}
+	}
+	if mask != nil {
+		req = mergeSettings(current, req, mask)
 	}
+
+	resp, err := s.client.UpdateSettings(ctx, req)
+	if err != nil {
+		return nil, dependencyError(depCustomer, err)
+	}
+	s.updateCache(ctx, req.UserId, resp)
+	setETag(ctx, resp.GetUpdatedAt())
 	return resp, nil
 }
 
-// AnalyzeReview отправляет отзыв в Kafka для асинхронного анализа
+// updateCache brings the cache in line with an update: in write-through mode the new settings
+// replace the cached ones, otherwise (or when they cannot be versioned or written) the entry is dropped
+func (s *Service) updateCache(ctx context.Context, userID string, resp *pb.UpdateUserSettingsResponse) {
+	if s.writeThrough.Load() && resp.GetUpdatedAt() != nil {
+		err := s.store.Replace(ctx, userID, settingsFromUpdate(resp))
+		if err == nil || errors.Is(err, redisstorage.ErrNewerCached) {
+			return
+		}
+		metrics.CacheSetFailures.Inc()
+		slog.WarnContext(ctx, "settings cache write-through failed, invalidating", "error", err)
+	}
+	if err := s.store.Invalidate(ctx, userID); err != nil {
+		slog.WarnContext(ctx, "settings cache invalidation failed", "error", err)
+	}
+}
+
+// settingsFromUpdate converts an update response into the cached settings shape
+func settingsFromUpdate(resp *pb.UpdateUserSettingsResponse) *pb.GetUserSettingsResponse {
+	return &pb.GetUserSettingsResponse{
+		Theme:       resp.GetTheme(),
+		PickedModel: resp.GetPickedModel(),
+		Font:        resp.GetFont(),
+		UpdatedAt:   resp.GetUpdatedAt(),
+	}
+}
+
+// lockSettings takes userID's settings lock. Without the lock a conditional update cannot
+// be checked and fails; an unconditional one proceeds as it did before locking existed
+func (s *Service) lockSettings(ctx context.Context, userID string, conditional bool) (func(), error) {
+	if s.locker == nil {
+		return func() {}, nil
+	}
+	wctx, cancel := context.WithTimeout(ctx, settingsLockWait)
+	defer cancel()
+	unlock, err := s.locker.Lock(wctx, "settings:"+userID, settingsLockTTL)
+	switch {
+	case err == nil:
+		return func() {
+			// Отпускаем и после отмены запроса, иначе следующее обновление ждало бы ttl
+			uctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), settingsLockWait)
+			defer cancel()
+			if err := unlock(uctx); err != nil {
+				slog.WarnContext(ctx, "failed to release settings lock", "error", err)
+			}
+		}, nil
+	case ctx.Err() != nil:
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Error(codes.Aborted, "another update of these settings is in progress, retry")
+	case !conditional:
+		slog.WarnContext(ctx, "settings lock unavailable, updating without it", "error", err)
+		return func() {}, nil
+	default:
+		return nil, dependencyError(depLock, err)
+	}
+}
+
+// currentSettings returns userID's settings for a read-modify-write: a fresh cache entry or
+// else a downstream read. The downstream result is not cached, since the update invalidates it anyway
+func (s *Service) currentSettings(ctx context.Context, userID string) (*pb.GetUserSettingsResponse, error) {
+	cached, err := s.store.Get(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "settings cache read failed", "error", err)
+	}
+	if cached != nil && cached.Freshness == redisstorage.Fresh {
+		return cached.Settings, nil
+	}
+	return s.client.GetSettings(ctx, &pb.GetUserSettingsRequest{UserId: userID})
+}
+
+// AnalyzeReview сохраняет отзыв в outbox для асинхронной отправки в Kafka и анализа
 func (s *Service) AnalyzeReview(ctx context.Context, req *pb.AnalyzeReviewRequest) (*pb.AnalyzeReviewResponse, error) {
+	if err := validation.Validate(req); err != nil {
+		return nil, err
+	}
+	// 0. Отсекаем запросы сверх лимитов до того, как что-то записать
+	if s.limiter != nil {
+		if err := s.limiter.AllowReview(ctx, req.UserId, req.Source); err != nil {
+			return nil, err
+		}
+	}
+
 	// 1. Генерируем UUID для отзыва
 	reviewID := uuid.New().String()
+	ctx = logging.With(ctx, slog.String(logging.KeyReviewID, reviewID))
 
 	// 2. Собираем пейлоад
 	payload := ReviewPayload{
@@ -106,15 +392,98 @@ func (s *Service) AnalyzeReview(ctx context.Context, req *pb.AnalyzeReviewReques
 		CreatedAt: time.Now(),
 	}
 
-	// 3. Отправляем в Kafka (асинхронно для клиента, синхронно для кода)
-	if err := s.producer.SendMessage(req.UserId, payload); err != nil {
-		log.Printf("Failed to send review to kafka: %v", err)
-		return nil, err
+	// 3. Записываем статус до отправки, чтобы результат из Kafka не обогнал его
+	s.saveReview(ctx, &reviewpb.ReviewStatus{
+		ReviewId:  reviewID,
+		UserId:    req.UserId,
+		Status:    ReviewStatusQueued,
+		UpdatedAt: timestamppb.New(payload.CreatedAt),
+	})
+
+	// 4. Пишем в outbox - relay доставит в Kafka с ретраями, даже если брокер сейчас недоступен
+	if err := s.producer.SendMessage(ctx, req.UserId, payload); err != nil {
+		slog.ErrorContext(ctx, "failed to write review to outbox", "error", err)
+		s.saveReview(ctx, &reviewpb.ReviewStatus{
+			ReviewId:  reviewID,
+			UserId:    req.UserId,
+			Status:    ReviewStatusFailed,
+			Error:     "failed to enqueue review",
+			UpdatedAt: timestamppb.Now(),
+		})
+		return nil, dependencyError(depQueue, err)
 	}
 
-	// 4. Сразу возвращаем ответ "В очереди"
+	// 5. Сразу возвращаем ответ "В очереди"
 	return &pb.AnalyzeReviewResponse{
 		ReviewId: reviewID,
-		Status:   "QUEUED",
+		Status:   ReviewStatusQueued,
 	}, nil
 }
+
+// GetReviewStatus returns the current lifecycle state of a review
+func (s *Service) GetReviewStatus(ctx context.Context, req *reviewpb.GetReviewStatusRequest) (*reviewpb.ReviewStatus, error) {
+	if err := validation.Validate(req); err != nil {
+		return nil, err
+	}
+	review, err := s.reviews.GetReview(ctx, req.ReviewId)
+	if err != nil {
+		slog.ErrorContext(ctx, "review status read failed", "error", err)
+		return nil, dependencyError(depReviews, err)
+	}
+	if review == nil {
+		return nil, status.Errorf(codes.NotFound, "review %s not found", req.ReviewId)
+	}
+	return review, nil
+}
+
+// WatchReview sends the current review state and then every transition until
+// the review reaches a terminal state or ctx is done
+func (s *Service) WatchReview(ctx context.Context, req *reviewpb.WatchReviewRequest, send func(*reviewpb.ReviewStatus) error) error {
+	if err := validation.Validate(req); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Подписываемся до чтения текущего состояния, чтобы не пропустить переход между ними
+	updates, err := s.reviews.WatchReview(ctx, req.ReviewId)
+	if err != nil {
+		slog.ErrorContext(ctx, "review updates subscription failed", "error", err)
+		return dependencyError(depReviews, err)
+	}
+	current, err := s.GetReviewStatus(ctx, &reviewpb.GetReviewStatusRequest{ReviewId: req.ReviewId})
+	if err != nil {
+		return err
+	}
+	if err := send(current); err != nil {
+		return err
+	}
+
+	last := current.Status
+	for !isTerminalReviewStatus(last) {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case update, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "review updates subscription closed")
+			}
+			// Pub/sub может доставить уже пройденный переход - отдаем только движение вперед
+			if reviewStatusRank(update.Status) <= reviewStatusRank(last) {
+				continue
+			}
+			if err := send(update); err != nil {
+				return err
+			}
+			last = update.Status
+		}
+	}
+	return nil
+}
+
+// saveReview persists review state; failures are logged since the review itself is not lost
+func (s *Service) saveReview(ctx context.Context, review *reviewpb.ReviewStatus) {
+	if err := s.reviews.SetReview(ctx, review); err != nil {
+		slog.ErrorContext(ctx, "failed to save review status", "status", review.Status, "error", err)
+	}
+}
@@ -3,13 +3,24 @@ package service
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	reviewpb "api-gateway/api/review"
+	"api-gateway/internal/metrics"
+	redisstorage "api-gateway/internal/storage/redis"
+
 	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
 )
 
@@ -18,12 +29,12 @@ type MockStorage struct {
 	mock.Mock
 }
 
-func (m *MockStorage) Get(ctx context.Context, userID string) (*pb.GetUserSettingsResponse, error) {
+func (m *MockStorage) Get(ctx context.Context, userID string) (*redisstorage.CachedSettings, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*pb.GetUserSettingsResponse), args.Error(1)
+	return args.Get(0).(*redisstorage.CachedSettings), args.Error(1)
 }
 
 func (m *MockStorage) Set(ctx context.Context, userID string, data *pb.GetUserSettingsResponse) error {
@@ -31,6 +42,11 @@ func (m *MockStorage) Set(ctx context.Context, userID string, data *pb.GetUserSe
 	return args.Error(0)
 }
 
+func (m *MockStorage) Replace(ctx context.Context, userID string, data *pb.GetUserSettingsResponse) error {
+	args := m.Called(ctx, userID, data)
+	return args.Error(0)
+}
+
 func (m *MockStorage) Invalidate(ctx context.Context, userID string) error {
 	args := m.Called(ctx, userID)
 	return args.Error(0)
@@ -70,16 +86,53 @@ type MockProducer struct {
 	mock.Mock
 }
 
-func (m *MockProducer) SendMessage(key string, value interface{}) error {
+func (m *MockProducer) SendMessage(ctx context.Context, key string, value interface{}) error {
 	args := m.Called(key, value)
 	return args.Error(0)
 }
 
+// MockLimiter mocks the ReviewLimiter interface
+type MockLimiter struct {
+	mock.Mock
+}
+
+func (m *MockLimiter) AllowReview(ctx context.Context, userID, source string) error {
+	args := m.Called(ctx, userID, source)
+	return args.Error(0)
+}
+
+// MockReviewStore mocks the redisstorage.ReviewStore interface
+type MockReviewStore struct {
+	mock.Mock
+}
+
+func (m *MockReviewStore) GetReview(ctx context.Context, reviewID string) (*reviewpb.ReviewStatus, error) {
+	args := m.Called(ctx, reviewID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*reviewpb.ReviewStatus), args.Error(1)
+}
+
+func (m *MockReviewStore) SetReview(ctx context.Context, review *reviewpb.ReviewStatus) error {
+	args := m.Called(ctx, review)
+	return args.Error(0)
+}
+
+func (m *MockReviewStore) WatchReview(ctx context.Context, reviewID string) (<-chan *reviewpb.ReviewStatus, error) {
+	args := m.Called(ctx, reviewID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(chan *reviewpb.ReviewStatus), args.Error(1)
+}
+
 // TestGetSettings_CacheHit tests cache-aside hit scenario
 func TestGetSettings_CacheHit(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	cachedResp := &pb.GetUserSettingsResponse{
 		Theme:       "dark",
@@ -88,10 +141,10 @@ func TestGetSettings_CacheHit(t *testing.T) {
 		UpdatedAt:   timestamppb.New(time.Now()),
 	}
 
-	mockStorage.On("Get", mock.Anything, "user123").Return(cachedResp, nil)
+	mockStorage.On("Get", mock.Anything, "user123").Return(&redisstorage.CachedSettings{Settings: cachedResp, StoredAt: time.Now()}, nil)
 
 	// Передаем mockProducer третьим аргументом
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 	req := &pb.GetUserSettingsRequest{UserId: "user123"}
 
 	resp, err := svc.GetSettings(context.Background(), req)
@@ -107,6 +160,7 @@ func TestGetSettings_CacheMiss(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	freshResp := &pb.GetUserSettingsResponse{
 		Theme:       "light",
@@ -121,7 +175,7 @@ func TestGetSettings_CacheMiss(t *testing.T) {
 		return req.UserId == "user456"
 	})).Return(freshResp, nil)
 
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 	req := &pb.GetUserSettingsRequest{UserId: "user456"}
 
 	resp, err := svc.GetSettings(context.Background(), req)
@@ -135,18 +189,95 @@ func TestGetSettings_CacheMiss(t *testing.T) {
 	mockStorage.AssertCalled(t, "Set", mock.Anything, "user456", freshResp)
 }
 
+// TestGetSettings_StaleWhileRevalidate tests that a soft-expired entry is served and refreshed in background
+func TestGetSettings_StaleWhileRevalidate(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	staleResp := &pb.GetUserSettingsResponse{Theme: "dark"}
+	freshResp := &pb.GetUserSettingsResponse{Theme: "light"}
+
+	mockStorage.On("Get", mock.Anything, "user321").
+		Return(&redisstorage.CachedSettings{Settings: staleResp, Freshness: redisstorage.Stale}, nil)
+	refreshed := make(chan struct{})
+	mockStorage.On("Set", mock.Anything, "user321", freshResp).Run(func(args mock.Arguments) {
+		close(refreshed)
+	}).Return(nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(freshResp, nil)
+
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
+
+	resp, err := svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{UserId: "user321"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, staleResp, resp)
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry was not refreshed in background")
+	}
+	mockClient.AssertNumberOfCalls(t, "GetSettings", 1)
+}
+
+// TestGetSettings_StaleIfError tests that an expired entry is served when customer service fails
+func TestGetSettings_StaleIfError(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	expiredResp := &pb.GetUserSettingsResponse{Theme: "dark"}
+
+	mockStorage.On("Get", mock.Anything, "user654").
+		Return(&redisstorage.CachedSettings{Settings: expiredResp, Freshness: redisstorage.Expired}, nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(nil, errors.New("customer service unavailable"))
+
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
+
+	resp, err := svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{UserId: "user654"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expiredResp, resp)
+	mockStorage.AssertNotCalled(t, "Set")
+}
+
+// TestGetSettings_ExpiredRefetched tests that an expired entry is replaced when customer service is up
+func TestGetSettings_ExpiredRefetched(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	freshResp := &pb.GetUserSettingsResponse{Theme: "light"}
+
+	mockStorage.On("Get", mock.Anything, "user655").
+		Return(&redisstorage.CachedSettings{Settings: &pb.GetUserSettingsResponse{Theme: "dark"}, Freshness: redisstorage.Expired}, nil)
+	mockStorage.On("Set", mock.Anything, "user655", freshResp).Return(nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(freshResp, nil)
+
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
+
+	resp, err := svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{UserId: "user655"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, freshResp, resp)
+}
+
 // TestGetSettings_ClientError tests error from downstream service
 func TestGetSettings_ClientError(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	mockStorage.On("Get", mock.Anything, "user789").Return(nil, nil)
 	mockClient.On("GetSettings", mock.Anything, mock.MatchedBy(func(req *pb.GetUserSettingsRequest) bool {
 		return req.UserId == "user789"
 	})).Return(nil, errors.New("customer service unavailable"))
 
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 	req := &pb.GetUserSettingsRequest{UserId: "user789"}
 
 	resp, err := svc.GetSettings(context.Background(), req)
@@ -161,6 +292,7 @@ func TestUpdateSettings_Success(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	updateResp := &pb.UpdateUserSettingsResponse{
 		Theme:       "dark",
@@ -174,7 +306,7 @@ func TestUpdateSettings_Success(t *testing.T) {
 	})).Return(updateResp, nil)
 	mockStorage.On("Invalidate", mock.Anything, "user999").Return(nil)
 
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 	req := &pb.UpdateUserSettingsRequest{
 		UserId:      "user999",
 		Theme:       "dark",
@@ -190,11 +322,342 @@ func TestUpdateSettings_Success(t *testing.T) {
 	mockStorage.AssertCalled(t, "Invalidate", mock.Anything, "user999")
 }
 
+func TestUpdateSettings_MaskMergesCachedSettings(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	cached := &pb.GetUserSettingsResponse{Theme: "light", PickedModel: "gpt-4", Font: "serif"}
+	mockStorage.On("Get", mock.Anything, "user1").Return(&redisstorage.CachedSettings{Settings: cached, Freshness: redisstorage.Fresh}, nil)
+	mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+	mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(&pb.UpdateUserSettingsResponse{Theme: "dark"}, nil)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(UpdateMaskHeader, "theme"))
+	// Font не в маске - пустое значение из запроса не должно затереть текущее
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark", Font: ""})
+
+	assert.NoError(t, err)
+	mockClient.AssertCalled(t, "UpdateSettings", mock.Anything, mock.MatchedBy(func(req *pb.UpdateUserSettingsRequest) bool {
+		return req.UserId == "user1" && req.Theme == "dark" && req.PickedModel == "gpt-4" && req.Font == "serif"
+	}))
+	mockClient.AssertNotCalled(t, "GetSettings", mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_MaskReadsDownstreamOnStaleCache(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	stale := &pb.GetUserSettingsResponse{Theme: "old", PickedModel: "old", Font: "old"}
+	mockStorage.On("Get", mock.Anything, "user1").Return(&redisstorage.CachedSettings{Settings: stale, Freshness: redisstorage.Stale}, nil)
+	mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(&pb.GetUserSettingsResponse{Theme: "light", PickedModel: "gpt-4", Font: "serif"}, nil)
+	mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(&pb.UpdateUserSettingsResponse{}, nil)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(UpdateMaskHeader, "font, picked_model"))
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", PickedModel: "claude", Font: "mono"})
+
+	assert.NoError(t, err)
+	mockClient.AssertCalled(t, "UpdateSettings", mock.Anything, mock.MatchedBy(func(req *pb.UpdateUserSettingsRequest) bool {
+		return req.Theme == "light" && req.PickedModel == "claude" && req.Font == "mono"
+	}))
+	mockStorage.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_MaskUnknownPath(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(UpdateMaskHeader, "theme,user_id"))
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockClient.AssertNotCalled(t, "UpdateSettings", mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_MaskReadError(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	mockStorage.On("Get", mock.Anything, "user1").Return(nil, nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(UpdateMaskHeader, "theme"))
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	mockClient.AssertNotCalled(t, "UpdateSettings", mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_IfMatch(t *testing.T) {
+	updatedAt := timestamppb.New(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name    string
+		ifMatch string
+	}{
+		{name: "etag", ifMatch: ETag(updatedAt)},
+		{name: "weak etag among others", ifMatch: `"other", W/` + ETag(updatedAt)},
+		{name: "updated_at", ifMatch: "2026-03-01T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorage)
+			mockClient := new(MockCustomerClient)
+
+			mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(&pb.GetUserSettingsResponse{Theme: "light", UpdatedAt: updatedAt}, nil)
+			mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(&pb.UpdateUserSettingsResponse{Theme: "dark"}, nil)
+			mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+
+			svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, tt.ifMatch))
+			_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+			assert.NoError(t, err)
+			mockClient.AssertNumberOfCalls(t, "UpdateSettings", 1)
+		})
+	}
+}
+
+func TestUpdateSettings_IfMatchConflict(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	current := &pb.GetUserSettingsResponse{Theme: "light", Font: "serif", UpdatedAt: timestamppb.New(time.Now())}
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(current, nil)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, ETag(timestamppb.New(time.Now().Add(-time.Hour)))))
+	before := testutil.ToFloat64(metrics.SettingsConflicts)
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	var got *pb.GetUserSettingsResponse
+	for _, d := range st.Details() {
+		if settings, ok := d.(*pb.GetUserSettingsResponse); ok {
+			got = settings
+		}
+	}
+	if assert.NotNil(t, got, "current settings must be attached") {
+		assert.Equal(t, "serif", got.Font)
+	}
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.SettingsConflicts))
+	mockClient.AssertNotCalled(t, "UpdateSettings", mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_IfMatchWithMask(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+
+	current := &pb.GetUserSettingsResponse{Theme: "light", PickedModel: "gpt-4", Font: "serif", UpdatedAt: timestamppb.New(time.Now())}
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Return(current, nil)
+	mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(&pb.UpdateUserSettingsResponse{}, nil)
+	mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, ETag(current.UpdatedAt), UpdateMaskHeader, "theme"))
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+	assert.NoError(t, err)
+	// Проверенная версия используется и для слияния - кэш не читается
+	mockStorage.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	mockClient.AssertCalled(t, "UpdateSettings", mock.Anything, mock.MatchedBy(func(req *pb.UpdateUserSettingsRequest) bool {
+		return req.Theme == "dark" && req.PickedModel == "gpt-4" && req.Font == "serif"
+	}))
+}
+
+func TestUpdateSettings_IfMatchInvalid(t *testing.T) {
+	mockClient := new(MockCustomerClient)
+
+	svc := New(new(MockStorage), mockClient, new(MockProducer), new(MockReviewStore), nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, "yesterday"))
+	_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockClient.AssertNotCalled(t, "GetSettings", mock.Anything, mock.Anything)
+}
+
+func TestUpdateSettings_WriteThrough(t *testing.T) {
+	updatedAt := timestamppb.New(time.Now())
+	resp := &pb.UpdateUserSettingsResponse{Theme: "dark", PickedModel: "gpt-4", Font: "mono", UpdatedAt: updatedAt}
+	cached := &pb.GetUserSettingsResponse{Theme: "dark", PickedModel: "gpt-4", Font: "mono", UpdatedAt: updatedAt}
+
+	tests := []struct {
+		name           string
+		resp           *pb.UpdateUserSettingsResponse
+		replaceErr     error
+		wantReplace    bool
+		wantInvalidate bool
+	}{
+		{name: "replaced", resp: resp, wantReplace: true},
+		{name: "newer already cached", resp: resp, replaceErr: redisstorage.ErrNewerCached, wantReplace: true},
+		{name: "replace failed", resp: resp, replaceErr: errors.New("redis down"), wantReplace: true, wantInvalidate: true},
+		{name: "no version", resp: &pb.UpdateUserSettingsResponse{Theme: "dark"}, wantInvalidate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorage)
+			mockClient := new(MockCustomerClient)
+
+			mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(tt.resp, nil)
+			mockStorage.On("Replace", mock.Anything, "user1", mock.Anything).Return(tt.replaceErr)
+			mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+
+			svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+			svc.SetCacheUpdateMode(CacheWriteThrough)
+			_, err := svc.UpdateSettings(context.Background(), &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+
+			assert.NoError(t, err)
+			if tt.wantReplace {
+				mockStorage.AssertCalled(t, "Replace", mock.Anything, "user1", mock.MatchedBy(func(s *pb.GetUserSettingsResponse) bool {
+					return proto.Equal(s, cached)
+				}))
+			} else {
+				mockStorage.AssertNotCalled(t, "Replace", mock.Anything, mock.Anything, mock.Anything)
+			}
+			if tt.wantInvalidate {
+				mockStorage.AssertCalled(t, "Invalidate", mock.Anything, "user1")
+			} else {
+				mockStorage.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// localLocker is an in-process redisstorage.Locker
+type localLocker struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+	// contended receives a value whenever a Lock call has to wait
+	contended chan struct{}
+}
+
+func (l *localLocker) Lock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, error) {
+	for {
+		l.mu.Lock()
+		held, ok := l.locks[name]
+		if !ok {
+			done := make(chan struct{})
+			l.locks[name] = done
+			l.mu.Unlock()
+			return func(context.Context) error {
+				l.mu.Lock()
+				delete(l.locks, name)
+				l.mu.Unlock()
+				close(done)
+				return nil
+			}, nil
+		}
+		l.mu.Unlock()
+		select {
+		case l.contended <- struct{}{}:
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-held:
+		}
+	}
+}
+
+// versionedCustomer keeps one user's settings and bumps updated_at on every write
+type versionedCustomer struct {
+	MockCustomerClient
+	mu       sync.Mutex
+	settings *pb.GetUserSettingsResponse
+	// writing is closed once the first write has started; writes then wait for release
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *versionedCustomer) GetSettings(ctx context.Context, req *pb.GetUserSettingsRequest) (*pb.GetUserSettingsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return proto.Clone(c.settings).(*pb.GetUserSettingsResponse), nil
+}
+
+func (c *versionedCustomer) UpdateSettings(ctx context.Context, req *pb.UpdateUserSettingsRequest) (*pb.UpdateUserSettingsResponse, error) {
+	c.once.Do(func() { close(c.writing) })
+	<-c.release
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	updatedAt := timestamppb.New(c.settings.UpdatedAt.AsTime().Add(time.Second))
+	c.settings = &pb.GetUserSettingsResponse{Theme: req.Theme, UpdatedAt: updatedAt}
+	return &pb.UpdateUserSettingsResponse{Theme: req.Theme, UpdatedAt: updatedAt}, nil
+}
+
+func TestUpdateSettings_ConcurrentConditionalUpdates(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+	customer := &versionedCustomer{
+		settings: &pb.GetUserSettingsResponse{Theme: "light", UpdatedAt: timestamppb.New(time.Now())},
+		writing:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	etag := ETag(customer.settings.UpdatedAt)
+
+	svc := New(mockStorage, customer, new(MockProducer), new(MockReviewStore), nil)
+	locker := &localLocker{locks: make(map[string]chan struct{}), contended: make(chan struct{}, 1)}
+	svc.SetSettingsLocker(locker)
+
+	// Оба устройства читали одну и ту же версию
+	errs := make(chan error, 2)
+	update := func(theme string) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, etag))
+		_, err := svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: theme})
+		errs <- err
+	}
+	go update("dark")
+	<-customer.writing
+	go update("blue")
+	// Второе обновление ждет блокировку, пока первое пишет - без нее оно прошло бы проверку версии
+	<-locker.contended
+	close(customer.release)
+
+	codesSeen := []codes.Code{status.Code(<-errs), status.Code(<-errs)}
+	assert.ElementsMatch(t, []codes.Code{codes.OK, codes.FailedPrecondition}, codesSeen)
+	assert.Equal(t, "dark", customer.settings.Theme)
+}
+
+func TestUpdateSettings_LockUnavailable(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockStorage.On("Invalidate", mock.Anything, "user1").Return(nil)
+	mockClient.On("UpdateSettings", mock.Anything, mock.Anything).Return(&pb.UpdateUserSettingsResponse{}, nil)
+
+	svc := New(mockStorage, mockClient, new(MockProducer), new(MockReviewStore), nil)
+	svc.SetSettingsLocker(failingLocker{})
+
+	// Безусловное обновление проходит и без блокировки
+	_, err := svc.UpdateSettings(context.Background(), &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+	assert.NoError(t, err)
+
+	// Условное - нет: версию нельзя проверить атомарно
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IfMatchHeader, `"abc"`))
+	_, err = svc.UpdateSettings(ctx, &pb.UpdateUserSettingsRequest{UserId: "user1", Theme: "dark"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	mockClient.AssertNumberOfCalls(t, "UpdateSettings", 1)
+}
+
+// failingLocker fails like a locker whose Redis is down
+type failingLocker struct{}
+
+func (failingLocker) Lock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, error) {
+	return nil, errors.New("connection refused")
+}
+
 // TestAnalyzeReview_Success (NEW TEST)
 func TestAnalyzeReview_Success(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	req := &pb.AnalyzeReviewRequest{
 		UserId: "user123",
@@ -208,8 +671,11 @@ func TestAnalyzeReview_Success(t *testing.T) {
 		// For now just checking it's not nil is enough for the mock match
 		return val != nil
 	})).Return(nil)
+	mockReviews.On("SetReview", mock.Anything, mock.MatchedBy(func(r *reviewpb.ReviewStatus) bool {
+		return r.Status == ReviewStatusQueued && r.UserId == "user123"
+	})).Return(nil)
 
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 
 	resp, err := svc.AnalyzeReview(context.Background(), req)
 
@@ -219,6 +685,7 @@ func TestAnalyzeReview_Success(t *testing.T) {
 	assert.NotEmpty(t, resp.ReviewId)
 
 	mockProducer.AssertExpectations(t)
+	mockReviews.AssertExpectations(t)
 }
 
 // TestAnalyzeReview_ProducerError (NEW TEST)
@@ -226,14 +693,234 @@ func TestAnalyzeReview_ProducerError(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockClient := new(MockCustomerClient)
 	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
 
 	mockProducer.On("SendMessage", mock.Anything, mock.Anything).Return(errors.New("kafka error"))
+	mockReviews.On("SetReview", mock.Anything, mock.Anything).Return(nil)
 
-	svc := New(mockStorage, mockClient, mockProducer)
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
 	req := &pb.AnalyzeReviewRequest{UserId: "u1", Text: "text"}
 
 	resp, err := svc.AnalyzeReview(context.Background(), req)
 
 	assert.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Nil(t, resp)
+	mockReviews.AssertCalled(t, "SetReview", mock.Anything, mock.MatchedBy(func(r *reviewpb.ReviewStatus) bool {
+		return r.Status == ReviewStatusFailed
+	}))
+}
+
+func TestAnalyzeReview_InvalidRequest(t *testing.T) {
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), mockProducer, mockReviews, nil)
+	resp, err := svc.AnalyzeReview(context.Background(), &pb.AnalyzeReviewRequest{UserId: "u1", Text: "   "})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	mockProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	mockReviews.AssertNotCalled(t, "SetReview", mock.Anything, mock.Anything)
+}
+
+// TestSettings_MissingUserID used to get (nil, nil), which gRPC reports as an internal marshal error
+func TestSettings_MissingUserID(t *testing.T) {
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), new(MockReviewStore), nil)
+
+	getResp, err := svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{})
+	assert.Nil(t, getResp)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	updResp, err := svc.UpdateSettings(context.Background(), nil)
+	assert.Nil(t, updResp)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDependencyError(t *testing.T) {
+	assert.NoError(t, dependencyError(depCustomer, nil))
+
+	downstream := status.Error(codes.NotFound, "user not found")
+	assert.Equal(t, downstream, dependencyError(depCustomer, downstream))
+
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(dependencyError(depQueue, context.DeadlineExceeded)))
+	assert.Equal(t, codes.Canceled, status.Code(dependencyError(depQueue, context.Canceled)))
+
+	err := dependencyError(depQueue, errors.New("dial tcp 10.0.0.1:6379: connection refused"))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.NotContains(t, err.Error(), "10.0.0.1", "infrastructure details stay in logs")
+}
+
+func TestAnalyzeReview_RateLimited(t *testing.T) {
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+	mockLimiter := new(MockLimiter)
+
+	mockLimiter.On("AllowReview", mock.Anything, "u1", "partner").
+		Return(status.Error(codes.ResourceExhausted, "user rate limit exceeded for AnalyzeReview"))
+
+	svc := New(new(MockStorage), new(MockCustomerClient), mockProducer, mockReviews, mockLimiter)
+	resp, err := svc.AnalyzeReview(context.Background(), &pb.AnalyzeReviewRequest{UserId: "u1", Text: "text", Source: "partner"})
+
 	assert.Nil(t, resp)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	mockProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	mockReviews.AssertNotCalled(t, "SetReview", mock.Anything, mock.Anything)
+}
+
+// TestGetReviewStatus_Found tests lookup of a known review
+func TestGetReviewStatus_Found(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	stored := &reviewpb.ReviewStatus{ReviewId: "uuid-1", UserId: "u1", Status: ReviewStatusDone}
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").Return(stored, nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	resp, err := svc.GetReviewStatus(context.Background(), &reviewpb.GetReviewStatusRequest{ReviewId: "uuid-1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, stored, resp)
+}
+
+// TestGetReviewStatus_NotFound tests lookup of an unknown review
+func TestGetReviewStatus_NotFound(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+	mockReviews.On("GetReview", mock.Anything, "missing").Return(nil, nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	resp, err := svc.GetReviewStatus(context.Background(), &reviewpb.GetReviewStatusRequest{ReviewId: "missing"})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestWatchReview_UntilDone tests that the stream follows transitions and stops at DONE
+func TestWatchReview_UntilDone(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	updates := make(chan *reviewpb.ReviewStatus, 3)
+	updates <- &reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusQueued}
+	updates <- &reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusProcessing}
+	updates <- &reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusDone}
+	mockReviews.On("WatchReview", mock.Anything, "uuid-1").Return(updates, nil)
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").
+		Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusQueued}, nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	var sent []string
+	err := svc.WatchReview(context.Background(), &reviewpb.WatchReviewRequest{ReviewId: "uuid-1"}, func(r *reviewpb.ReviewStatus) error {
+		sent = append(sent, r.Status)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ReviewStatusQueued, ReviewStatusProcessing, ReviewStatusDone}, sent)
+}
+
+// TestWatchReview_AlreadyDone tests that a finished review is sent once and the stream ends
+func TestWatchReview_AlreadyDone(t *testing.T) {
+	mockReviews := new(MockReviewStore)
+
+	mockReviews.On("WatchReview", mock.Anything, "uuid-1").Return(make(chan *reviewpb.ReviewStatus), nil)
+	mockReviews.On("GetReview", mock.Anything, "uuid-1").
+		Return(&reviewpb.ReviewStatus{ReviewId: "uuid-1", Status: ReviewStatusFailed}, nil)
+
+	svc := New(new(MockStorage), new(MockCustomerClient), new(MockProducer), mockReviews, nil)
+
+	calls := 0
+	err := svc.WatchReview(context.Background(), &reviewpb.WatchReviewRequest{ReviewId: "uuid-1"}, func(r *reviewpb.ReviewStatus) error {
+		calls++
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestGetSettings_CoalescesConcurrentMisses tests that concurrent misses for one user make a single downstream call
+func TestGetSettings_CoalescesConcurrentMisses(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	freshResp := &pb.GetUserSettingsResponse{Theme: "dark", PickedModel: "gpt-4", Font: "serif"}
+	release := make(chan struct{})
+	var downstreamCalls, misses atomic.Int32
+
+	mockStorage.On("Get", mock.Anything, "hot-user").Run(func(args mock.Arguments) {
+		misses.Add(1)
+	}).Return(nil, nil)
+	mockStorage.On("Set", mock.Anything, "hot-user", mock.Anything).Return(nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		downstreamCalls.Add(1)
+		<-release
+	}).Return(freshResp, nil)
+
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
+
+	const callers = 50
+	coalescedBefore := testutil.ToFloat64(metrics.SettingsCoalesced)
+	fetchesBefore := testutil.ToFloat64(metrics.SettingsFetches)
+
+	var wg sync.WaitGroup
+	results := make([]*pb.GetUserSettingsResponse, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{UserId: "hot-user"})
+		}(i)
+	}
+
+	// Let every caller reach the cache miss before the downstream call returns;
+	// from there it joins the shared fetch without blocking
+	assert.Eventually(t, func() bool {
+		return downstreamCalls.Load() == 1 && misses.Load() == callers
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), downstreamCalls.Load())
+	for i := 0; i < callers; i++ {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, "dark", results[i].Theme)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.SettingsFetches)-fetchesBefore)
+	assert.Equal(t, float64(callers-1), testutil.ToFloat64(metrics.SettingsCoalesced)-coalescedBefore)
+}
+
+// TestGetSettings_CallerCancelDoesNotFailOthers tests that a cancelled waiter leaves the shared fetch running
+func TestGetSettings_CallerCancelDoesNotFailOthers(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockClient := new(MockCustomerClient)
+	mockProducer := new(MockProducer)
+	mockReviews := new(MockReviewStore)
+
+	release := make(chan struct{})
+	mockStorage.On("Get", mock.Anything, "u1").Return(nil, nil)
+	mockStorage.On("Set", mock.Anything, "u1", mock.Anything).Return(nil)
+	mockClient.On("GetSettings", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-release
+	}).Return(&pb.GetUserSettingsResponse{Theme: "light"}, nil)
+
+	svc := New(mockStorage, mockClient, mockProducer, mockReviews, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := svc.GetSettings(ctx, &pb.GetUserSettingsRequest{UserId: "u1"})
+		done <- err
+	}()
+	cancel()
+	assert.Equal(t, codes.Canceled, status.Code(<-done))
+
+	go close(release)
+	resp, err := svc.GetSettings(context.Background(), &pb.GetUserSettingsRequest{UserId: "u1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "light", resp.Theme)
 }
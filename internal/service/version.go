@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"api-gateway/internal/validation"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+const (
+	// ETagHeader is set in GetUserSettings and UpdateUserSettings response metadata to the settings version
+	ETagHeader = "etag"
+	// IfMatchHeader makes UpdateUserSettings conditional: it holds the ETag or the updated_at
+	// (RFC 3339) the client last read, several values may be comma-separated and * matches any version
+	IfMatchHeader = "if-match"
+)
+
+// ETag derives the settings version from updated_at
+func ETag(updatedAt *timestamppb.Timestamp) string {
+	return `"` + strconv.FormatInt(updatedAt.AsTime().UnixNano(), 36) + `"`
+}
+
+// setETag tells the caller the version of the returned settings
+func setETag(ctx context.Context, updatedAt *timestamppb.Timestamp) {
+	if updatedAt == nil {
+		return
+	}
+	// Вне gRPC-запроса (например, в тестах) заголовок выставить нельзя - это не ошибка
+	_ = grpc.SetHeader(ctx, metadata.Pairs(ETagHeader, ETag(updatedAt)))
+}
+
+// ifMatch reads the versions an update is conditional on; nil means the update is unconditional
+func ifMatch(ctx context.Context) ([]string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var etags []string
+	for _, v := range md.Get(IfMatchHeader) {
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			switch {
+			case tag == "":
+				continue
+			case tag == "*":
+				// Настройки есть у любого пользователя - проверять нечего
+				return nil, nil
+			case strings.HasPrefix(tag, `"`), strings.HasPrefix(tag, `W/"`):
+				// Слабые ETag сравниваем как сильные: версия у настроек одна
+				etags = append(etags, strings.TrimPrefix(tag, "W/"))
+			default:
+				t, err := time.Parse(time.RFC3339Nano, tag)
+				if err != nil {
+					return nil, validation.Invalid(&errdetails.BadRequest_FieldViolation{
+						Field:       IfMatchHeader,
+						Description: fmt.Sprintf("%q is neither an ETag nor an RFC 3339 timestamp", tag),
+					})
+				}
+				etags = append(etags, ETag(timestamppb.New(t)))
+			}
+		}
+	}
+	return etags, nil
+}
+
+// checkVersion returns FailedPrecondition with the current settings attached
+// unless their version is one of etags
+func checkVersion(ctx context.Context, userID string, current *pb.GetUserSettingsResponse, etags []string) error {
+	version := ETag(current.GetUpdatedAt())
+	for _, tag := range etags {
+		if tag == version {
+			return nil
+		}
+	}
+	setETag(ctx, current.GetUpdatedAt())
+	st, err := status.New(codes.FailedPrecondition, "settings were modified since they were read; merge with the current settings and retry").
+		WithDetails(
+			&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        "VERSION",
+				Subject:     "users/" + userID + "/settings",
+				Description: "current version is " + version,
+			}}},
+			current,
+		)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "settings were modified since they were read, current version is %s", version)
+	}
+	return st.Err()
+}
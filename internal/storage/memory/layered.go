@@ -0,0 +1,103 @@
+package memorystorage
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	redisstorage "api-gateway/internal/storage/redis"
+
+	customer "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// Bus broadcasts invalidations between replicas (redisstorage.InvalidationBus)
+type Bus interface {
+	Publish(ctx context.Context, userID string) error
+	Subscribe(ctx context.Context, handler func(userID string)) error
+}
+
+// Layered serves settings from the in-process L1 and falls back to L2 (Redis).
+// Invalidations are broadcast so every replica drops its L1 copy; if a broadcast
+// is lost, the L1 TTL bounds how long a replica can serve the old value
+type Layered struct {
+	l1  *Cache
+	l2  redisstorage.Storage
+	bus Bus
+
+	// epoch changes on every invalidation, so a Get racing with one never refills L1 with old data
+	epoch atomic.Uint64
+}
+
+// NewLayered composes l1 and l2 and starts listening for remote invalidations until ctx is done
+func NewLayered(ctx context.Context, l1 *Cache, l2 redisstorage.Storage, bus Bus) (*Layered, error) {
+	s := &Layered{l1: l1, l2: l2, bus: bus}
+	if err := bus.Subscribe(ctx, s.evict); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get checks L1, then L2, filling L1 on an L2 hit
+func (s *Layered) Get(ctx context.Context, userID string) (*redisstorage.CachedSettings, error) {
+	if entry, _ := s.l1.Get(ctx, userID); entry != nil {
+		return entry, nil
+	}
+	epoch := s.epoch.Load()
+	entry, err := s.l2.Get(ctx, userID)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	// Expired entries are only useful for stale-if-error, keep them out of L1
+	if entry.Freshness != redisstorage.Expired && s.epoch.Load() == epoch {
+		s.l1.Put(userID, entry)
+	}
+	return entry, nil
+}
+
+// SetTTL changes the cache lifetimes of both tiers
+func (s *Layered) SetTTL(ttl redisstorage.CacheTTL) {
+	s.l1.SetTTL(ttl)
+	if setter, ok := s.l2.(redisstorage.TTLSetter); ok {
+		setter.SetTTL(ttl)
+	}
+}
+
+// Set writes through to L2 and then L1; settings L2 rejected as outdated stay out of L1
+func (s *Layered) Set(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error {
+	epoch := s.epoch.Load()
+	if err := s.l2.Set(ctx, userID, data); err != nil {
+		return err
+	}
+	if s.epoch.Load() == epoch {
+		s.l1.Set(ctx, userID, data)
+	}
+	return nil
+}
+
+// Replace writes the new settings to L2 and makes every replica drop its L1 copy,
+// so the next read anywhere picks them up from L2
+func (s *Layered) Replace(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error {
+	err := s.l2.Replace(ctx, userID, data)
+	// L1 не заполняем: L2 мог отклонить запись как более старую, а истину знает только он
+	s.evict(userID)
+	if pubErr := s.bus.Publish(ctx, userID); pubErr != nil {
+		slog.WarnContext(ctx, "failed to broadcast settings invalidation", "error", pubErr)
+	}
+	return err
+}
+
+// Invalidate removes the entry from both tiers and tells other replicas to drop their L1 copy
+func (s *Layered) Invalidate(ctx context.Context, userID string) error {
+	err := s.l2.Invalidate(ctx, userID)
+	// Evict after L2 is cleared: a Get that read the old L2 value before this point is now outdated
+	s.evict(userID)
+	if pubErr := s.bus.Publish(ctx, userID); pubErr != nil {
+		slog.WarnContext(ctx, "failed to broadcast settings invalidation", "error", pubErr)
+	}
+	return err
+}
+
+func (s *Layered) evict(userID string) {
+	s.epoch.Add(1)
+	s.l1.Invalidate(context.Background(), userID)
+}
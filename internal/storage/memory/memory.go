@@ -0,0 +1,137 @@
+package memorystorage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	redisstorage "api-gateway/internal/storage/redis"
+
+	customer "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// Cache is an in-process LRU of user settings with a per-entry TTL.
+// Freshness is computed from the entry's age on every Get, with the same rules as in Redis.
+// It implements redisstorage.Storage so it can sit in front of Redis
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+	now     func() time.Time
+
+	// lifetimes are the soft and hard TTLs freshness is computed with
+	lifetimes atomic.Pointer[redisstorage.CacheTTL]
+}
+
+type cacheItem struct {
+	userID    string
+	settings  *customer.GetUserSettingsResponse
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// New creates a cache holding at most size entries for ttl each.
+// Freshness follows redisstorage.DefaultCacheTTL until SetTTL is called
+func New(size int, ttl time.Duration) *Cache {
+	c := &Cache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+	c.SetTTL(redisstorage.DefaultCacheTTL())
+	return c
+}
+
+// SetTTL changes the lifetimes freshness is computed with; the L1 ttl stays as created
+func (c *Cache) SetTTL(ttl redisstorage.CacheTTL) {
+	c.lifetimes.Store(&ttl)
+}
+
+// Get returns a copy of the cached entry or nil if it is missing, past the L1 ttl or past the hard TTL.
+// Expired entries are left to the lower tier, which keeps them for stale-if-error
+func (c *Cache) Get(_ context.Context, userID string) (*redisstorage.CachedSettings, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[userID]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*cacheItem)
+	now := c.now()
+	freshness := c.lifetimes.Load().Freshness(now.Sub(item.storedAt))
+	if now.After(item.expiresAt) || freshness == redisstorage.Expired {
+		c.remove(el)
+		return nil, nil
+	}
+	c.order.MoveToFront(el)
+	return &redisstorage.CachedSettings{
+		Settings:  proto.Clone(item.settings).(*customer.GetUserSettingsResponse),
+		StoredAt:  item.storedAt,
+		Freshness: freshness,
+	}, nil
+}
+
+// Set stores freshly fetched settings
+func (c *Cache) Set(_ context.Context, userID string, data *customer.GetUserSettingsResponse) error {
+	c.Put(userID, &redisstorage.CachedSettings{Settings: data, StoredAt: c.now()})
+	return nil
+}
+
+// Replace stores settings just written downstream
+func (c *Cache) Replace(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error {
+	return c.Set(ctx, userID, data)
+}
+
+// Put stores an entry as read from a lower cache tier, keeping its age; its freshness is recomputed on Get
+func (c *Cache) Put(userID string, entry *redisstorage.CachedSettings) {
+	if c.size <= 0 {
+		return
+	}
+	settings := proto.Clone(entry.Settings).(*customer.GetUserSettingsResponse)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := c.now().Add(c.ttl)
+	if el, ok := c.entries[userID]; ok {
+		item := el.Value.(*cacheItem)
+		item.settings = settings
+		item.storedAt = entry.StoredAt
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[userID] = c.order.PushFront(&cacheItem{userID: userID, settings: settings, storedAt: entry.StoredAt, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate removes the entry for user
+func (c *Cache) Invalidate(_ context.Context, userID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[userID]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries currently held
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheItem).userID)
+}
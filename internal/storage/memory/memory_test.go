@@ -0,0 +1,219 @@
+package memorystorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	redisstorage "api-gateway/internal/storage/redis"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// MockStorage mocks the redisstorage.Storage interface (L2)
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) Get(ctx context.Context, userID string) (*redisstorage.CachedSettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*redisstorage.CachedSettings), args.Error(1)
+}
+
+func (m *MockStorage) Set(ctx context.Context, userID string, data *pb.GetUserSettingsResponse) error {
+	args := m.Called(ctx, userID, data)
+	return args.Error(0)
+}
+
+func (m *MockStorage) Replace(ctx context.Context, userID string, data *pb.GetUserSettingsResponse) error {
+	args := m.Called(ctx, userID, data)
+	return args.Error(0)
+}
+
+func (m *MockStorage) Invalidate(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// localBus delivers published invalidations to subscribers in-process
+type localBus struct {
+	handlers []func(string)
+	sent     []string
+}
+
+func (b *localBus) Publish(ctx context.Context, userID string) error {
+	b.sent = append(b.sent, userID)
+	for _, h := range b.handlers {
+		h(userID)
+	}
+	return nil
+}
+
+func (b *localBus) Subscribe(ctx context.Context, handler func(userID string)) error {
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2, time.Minute)
+	ctx := context.Background()
+
+	c.Set(ctx, "a", &pb.GetUserSettingsResponse{Theme: "a"})
+	c.Set(ctx, "b", &pb.GetUserSettingsResponse{Theme: "b"})
+	// touch "a" so that "b" becomes the eviction candidate
+	got, _ := c.Get(ctx, "a")
+	assert.NotNil(t, got)
+	c.Set(ctx, "c", &pb.GetUserSettingsResponse{Theme: "c"})
+
+	assert.Equal(t, 2, c.Len())
+	b, _ := c.Get(ctx, "b")
+	assert.Nil(t, b)
+	a, _ := c.Get(ctx, "a")
+	assert.Equal(t, "a", a.Settings.Theme)
+}
+
+func TestCache_ExpiresAfterTTL(t *testing.T) {
+	c := New(10, time.Second)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+	got, _ := c.Get(ctx, "u1")
+	assert.NotNil(t, got)
+
+	now = now.Add(2 * time.Second)
+	got, _ = c.Get(ctx, "u1")
+	assert.Nil(t, got)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_ReturnsCopies(t *testing.T) {
+	c := New(10, time.Minute)
+	ctx := context.Background()
+
+	c.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+	got, _ := c.Get(ctx, "u1")
+	got.Settings.Theme = "mutated"
+
+	again, _ := c.Get(ctx, "u1")
+	assert.Equal(t, "dark", again.Settings.Theme)
+}
+
+func TestCache_ComputesFreshnessOnGet(t *testing.T) {
+	c := New(10, time.Hour)
+	c.SetTTL(redisstorage.CacheTTL{Soft: time.Minute, Hard: 10 * time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+	got, _ := c.Get(ctx, "u1")
+	assert.Equal(t, redisstorage.Fresh, got.Freshness)
+
+	// Копия из L2 была свежей, но стареет по своему stored_at, а не по времени копирования
+	c.Put("u2", &redisstorage.CachedSettings{Settings: &pb.GetUserSettingsResponse{}, StoredAt: now.Add(-50 * time.Second), Freshness: redisstorage.Fresh})
+	now = now.Add(20 * time.Second)
+	got, _ = c.Get(ctx, "u2")
+	assert.Equal(t, redisstorage.Stale, got.Freshness)
+	got, _ = c.Get(ctx, "u1")
+	assert.Equal(t, redisstorage.Fresh, got.Freshness)
+
+	// Истекшие записи L1 не отдает - для stale-if-error они есть в L2
+	now = now.Add(10 * time.Minute)
+	got, _ = c.Get(ctx, "u1")
+	assert.Nil(t, got)
+}
+
+func TestLayered_FillsL1FromL2(t *testing.T) {
+	l2 := new(MockStorage)
+	l2.On("Get", mock.Anything, "u1").
+		Return(&redisstorage.CachedSettings{Settings: &pb.GetUserSettingsResponse{Theme: "dark"}, StoredAt: time.Now()}, nil).Once()
+
+	s, err := NewLayered(context.Background(), New(10, time.Minute), l2, &localBus{})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		got, err := s.Get(context.Background(), "u1")
+		assert.NoError(t, err)
+		assert.Equal(t, "dark", got.Settings.Theme)
+	}
+	l2.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestLayered_SkipsExpiredEntries(t *testing.T) {
+	l2 := new(MockStorage)
+	l2.On("Get", mock.Anything, "u1").
+		Return(&redisstorage.CachedSettings{Settings: &pb.GetUserSettingsResponse{}, Freshness: redisstorage.Expired}, nil)
+
+	l1 := New(10, time.Minute)
+	s, _ := NewLayered(context.Background(), l1, l2, &localBus{})
+
+	s.Get(context.Background(), "u1")
+	assert.Equal(t, 0, l1.Len())
+}
+
+func TestLayered_InvalidateReachesOtherReplicas(t *testing.T) {
+	bus := &localBus{}
+	ctx := context.Background()
+
+	l2 := new(MockStorage)
+	l2.On("Invalidate", mock.Anything, "u1").Return(nil)
+
+	localL1, remoteL1 := New(10, time.Minute), New(10, time.Minute)
+	local, _ := NewLayered(ctx, localL1, l2, bus)
+	_, _ = NewLayered(ctx, remoteL1, new(MockStorage), bus)
+
+	localL1.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+	remoteL1.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+
+	assert.NoError(t, local.Invalidate(ctx, "u1"))
+
+	assert.Equal(t, 0, localL1.Len())
+	assert.Equal(t, 0, remoteL1.Len())
+	assert.Equal(t, []string{"u1"}, bus.sent)
+	l2.AssertCalled(t, "Invalidate", mock.Anything, "u1")
+}
+
+func TestLayered_ReplaceDropsL1Everywhere(t *testing.T) {
+	bus := &localBus{}
+	ctx := context.Background()
+	updated := &pb.GetUserSettingsResponse{Theme: "light"}
+
+	l2 := new(MockStorage)
+	l2.On("Replace", mock.Anything, "u1", updated).Return(nil)
+
+	localL1, remoteL1 := New(10, time.Minute), New(10, time.Minute)
+	local, _ := NewLayered(ctx, localL1, l2, bus)
+	_, _ = NewLayered(ctx, remoteL1, new(MockStorage), bus)
+
+	localL1.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+	remoteL1.Set(ctx, "u1", &pb.GetUserSettingsResponse{Theme: "dark"})
+
+	assert.NoError(t, local.Replace(ctx, "u1", updated))
+
+	assert.Equal(t, 0, localL1.Len())
+	assert.Equal(t, 0, remoteL1.Len())
+	assert.Equal(t, []string{"u1"}, bus.sent)
+	l2.AssertNotCalled(t, "Invalidate", mock.Anything, mock.Anything)
+}
+
+func TestLayered_SetRejectedByL2SkipsL1(t *testing.T) {
+	ctx := context.Background()
+	old := &pb.GetUserSettingsResponse{Theme: "dark"}
+
+	l2 := new(MockStorage)
+	l2.On("Set", mock.Anything, "u1", old).Return(redisstorage.ErrNewerCached)
+
+	l1 := New(10, time.Minute)
+	s, _ := NewLayered(ctx, l1, l2, &localBus{})
+
+	assert.ErrorIs(t, s.Set(ctx, "u1", old), redisstorage.ErrNewerCached)
+	assert.Equal(t, 0, l1.Len())
+}
@@ -0,0 +1,140 @@
+package redisstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyRecord is stored under an idempotency key: a claim while the first call
+// is in flight, then the response it produced
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request payload the key was first used with
+	Fingerprint string `json:"fingerprint"`
+	// Owner identifies the call holding the claim
+	Owner string `json:"owner,omitempty"`
+	// Response is the serialized response; empty while the call is in flight
+	Response []byte `json:"response,omitempty"`
+}
+
+// Completed reports whether the record holds a response
+func (r *IdempotencyRecord) Completed() bool {
+	return len(r.Response) > 0
+}
+
+// ErrClaimLost is returned by Complete when the claim no longer belongs to the caller:
+// its lease ran out and another call took the key over
+var ErrClaimLost = errors.New("idempotency claim is held by another call")
+
+// IdempotencyStore keeps the outcome of calls made with an idempotency key
+type IdempotencyStore interface {
+	// Claim stores rec under key for lease unless the key is taken; then the existing record is returned
+	Claim(ctx context.Context, key string, rec IdempotencyRecord, lease time.Duration) (*IdempotencyRecord, error)
+	// Complete replaces the claim still held by owner with the final record, kept for ttl;
+	// otherwise it returns ErrClaimLost
+	Complete(ctx context.Context, key, owner string, rec IdempotencyRecord, ttl time.Duration) error
+	// Release drops a claim still held by owner, so the call can be retried
+	Release(ctx context.Context, key, owner string) error
+}
+
+// claimScript sets the key unless it exists, atomically returning the existing value.
+//
+// KEYS: record key; ARGV: record, lease in ms.
+// Returns nil when claimed, otherwise the stored record
+var claimScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return false
+end
+return redis.call('GET', KEYS[1])
+`)
+
+// releaseScript deletes an in-flight claim only if it still belongs to the caller,
+// so a call whose lease ran out cannot drop the claim of the one that took over.
+//
+// KEYS: record key; ARGV: owner. Returns 1 when deleted
+var releaseScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+local ok, rec = pcall(cjson.decode, current)
+if ok and type(rec) == 'table' and rec.owner == ARGV[1] and rec.response == nil then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// completeScript stores the final record only over the caller's own in-flight claim,
+// so a call whose lease ran out cannot overwrite the outcome of the one that took over.
+//
+// KEYS: record key; ARGV: owner, record, ttl in ms. Returns 1 when stored
+var completeScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return 0
+end
+local ok, rec = pcall(cjson.decode, current)
+if ok and type(rec) == 'table' and rec.owner == ARGV[1] and rec.response == nil then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// redisIdempotencyStore implements IdempotencyStore
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+// NewIdempotencyStore creates idempotency key storage on top of an existing redis client
+func NewIdempotencyStore(client *redis.Client) IdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+// Claim runs claimScript
+func (r *redisIdempotencyStore) Claim(ctx context.Context, key string, rec IdempotencyRecord, lease time.Duration) (*IdempotencyRecord, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	val, err := claimScript.Run(ctx, r.client, []string{r.key(key)}, string(b), lease.Milliseconds()).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var existing IdempotencyRecord
+	if err := json.Unmarshal([]byte(val), &existing); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Complete runs completeScript
+func (r *redisIdempotencyStore) Complete(ctx context.Context, key, owner string, rec IdempotencyRecord, ttl time.Duration) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	stored, err := completeScript.Run(ctx, r.client, []string{r.key(key)}, owner, string(b), ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if stored == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// Release runs releaseScript
+func (r *redisIdempotencyStore) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, r.client, []string{r.key(key)}, owner).Err()
+}
+
+func (r *redisIdempotencyStore) key(key string) string {
+	return "idempotency:" + key
+}
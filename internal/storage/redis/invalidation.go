@@ -0,0 +1,51 @@
+package redisstorage
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel carries user IDs whose settings changed on any replica
+const invalidationChannel = "user:settings:invalidate"
+
+// InvalidationBus broadcasts settings invalidations to every gateway replica
+type InvalidationBus struct {
+	client *redis.Client
+}
+
+// NewInvalidationBus creates a bus on top of an existing redis client
+func NewInvalidationBus(client *redis.Client) *InvalidationBus {
+	return &InvalidationBus{client: client}
+}
+
+// Publish announces that userID's settings must be dropped from local caches
+func (b *InvalidationBus) Publish(ctx context.Context, userID string) error {
+	return b.client.Publish(ctx, invalidationChannel, userID).Err()
+}
+
+// Subscribe calls handler for every published invalidation until ctx is done.
+// It returns once the subscription is confirmed; delivery continues in background
+func (b *InvalidationBus) Subscribe(ctx context.Context, handler func(userID string)) error {
+	sub := b.client.Subscribe(ctx, invalidationChannel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return err
+	}
+	go func() {
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				handler(msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,68 @@
+package redisstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// lockRetryInterval is how often a taken lock is polled while waiting for it
+const lockRetryInterval = 20 * time.Millisecond
+
+// Locker provides short exclusive locks shared by all gateway replicas
+type Locker interface {
+	// Lock waits until name is free or ctx is done, then holds it for at most ttl.
+	// The returned function releases the lock if it is still held
+	Lock(ctx context.Context, name string, ttl time.Duration) (unlock func(context.Context) error, err error)
+}
+
+// unlockScript deletes the lock only if it still holds the caller's token,
+// so a holder whose ttl ran out cannot release the lock of the next one.
+//
+// KEYS: lock key; ARGV: token. Returns 1 when released
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// redisLocker implements Locker with SET NX PX
+type redisLocker struct {
+	client *redis.Client
+}
+
+// NewLocker creates a locker on top of an existing redis client
+func NewLocker(client *redis.Client) Locker {
+	return &redisLocker{client: client}
+}
+
+// Lock polls SET NX until it succeeds
+func (r *redisLocker) Lock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, error) {
+	key := r.key(name)
+	token := uuid.New().String()
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := r.client.SetNX(ctx, key, token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func(ctx context.Context) error {
+				return unlockScript.Run(ctx, r.client, []string{key}, token).Err()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *redisLocker) key(name string) string {
+	return "lock:" + name
+}
@@ -0,0 +1,205 @@
+package redisstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"api-gateway/internal/logging"
+)
+
+const (
+	outboxStream = "outbox:reviews"
+	outboxGroup  = "relay"
+	// outboxDeadStream keeps messages that could never be published, for inspection and replay
+	outboxDeadStream = "outbox:reviews:dead"
+	// outboxDeadMaxLen bounds the dead-letter stream; older entries are trimmed first
+	outboxDeadMaxLen = 100000
+	// outboxWriteTimeout bounds SendMessage, which outlives the caller's cancellation
+	outboxWriteTimeout = 3 * time.Second
+	// outboxHeaderPrefix marks stream fields carrying trace context
+	outboxHeaderPrefix = "h:"
+)
+
+// OutboxMessage is an accepted event waiting to be published
+type OutboxMessage struct {
+	ID      string
+	Key     string
+	Payload []byte
+	// Headers carry the trace context and request ID of the request that produced the message
+	Headers map[string]string
+}
+
+// Outbox is a durable queue of events in front of the message broker.
+// SendMessage has the same signature as the Kafka producer, so the service
+// can write to the outbox without knowing about the relay behind it
+type Outbox interface {
+	SendMessage(ctx context.Context, key string, value interface{}) error
+	// Init creates the consumer group used by relays
+	Init(ctx context.Context) error
+	// Read returns new messages for consumer, blocking up to block if there are none
+	Read(ctx context.Context, consumer string, count int64, block time.Duration) ([]OutboxMessage, error)
+	// Reclaim takes over messages left unacknowledged longer than minIdle (e.g. by a crashed relay)
+	Reclaim(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]OutboxMessage, error)
+	// Ack marks messages as delivered and removes them from the stream
+	Ack(ctx context.Context, ids ...string) error
+	// DeadLetter moves a message that can never be published to the dead-letter stream, recording reason
+	DeadLetter(ctx context.Context, msg OutboxMessage, reason string) error
+	// PruneConsumers removes relay consumers other than keep that hold no messages and were idle longer
+	// than idle, e.g. those of replicas that have restarted under a new name
+	PruneConsumers(ctx context.Context, keep string, idle time.Duration) error
+}
+
+// redisOutbox implements Outbox on top of a Redis stream
+type redisOutbox struct {
+	client *redis.Client
+}
+
+// NewOutbox creates an outbox on top of an existing redis client
+func NewOutbox(client *redis.Client) Outbox {
+	return &redisOutbox{client: client}
+}
+
+// SendMessage appends value as JSON to the outbox stream, together with the trace context and request ID of ctx.
+// The write is not cancelled with ctx: once the caller got this far the review should be kept
+func (o *redisOutbox) SendMessage(ctx context.Context, key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshalling error: %w", err)
+	}
+	values := map[string]interface{}{"key": key, "payload": string(b)}
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+	if id := logging.RequestID(ctx); id != "" {
+		headers.Set(logging.RequestIDHeader, id)
+	}
+	for k, v := range headers {
+		values[outboxHeaderPrefix+k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), outboxWriteTimeout)
+	defer cancel()
+	err = o.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: outboxStream,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("outbox write error: %w", err)
+	}
+	return nil
+}
+
+func (o *redisOutbox) Init(ctx context.Context) error {
+	err := o.client.XGroupCreateMkStream(ctx, outboxStream, outboxGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (o *redisOutbox) Read(ctx context.Context, consumer string, count int64, block time.Duration) ([]OutboxMessage, error) {
+	streams, err := o.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    outboxGroup,
+		Consumer: consumer,
+		Streams:  []string{outboxStream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msgs []OutboxMessage
+	for _, s := range streams {
+		msgs = append(msgs, toOutboxMessages(s.Messages)...)
+	}
+	return msgs, nil
+}
+
+func (o *redisOutbox) Reclaim(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]OutboxMessage, error) {
+	msgs, _, err := o.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   outboxStream,
+		Group:    outboxGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    count,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toOutboxMessages(msgs), nil
+}
+
+func (o *redisOutbox) Ack(ctx context.Context, ids ...string) error {
+	_, err := o.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, outboxStream, outboxGroup, ids...)
+		pipe.XDel(ctx, outboxStream, ids...)
+		return nil
+	})
+	return err
+}
+
+// DeadLetter appends msg with reason to the dead-letter stream and removes it from the outbox in one transaction
+func (o *redisOutbox) DeadLetter(ctx context.Context, msg OutboxMessage, reason string) error {
+	values := map[string]interface{}{"key": msg.Key, "payload": string(msg.Payload), "error": reason, "outbox_id": msg.ID}
+	for k, v := range msg.Headers {
+		values[outboxHeaderPrefix+k] = v
+	}
+	_, err := o.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: outboxDeadStream,
+			MaxLen: outboxDeadMaxLen,
+			Approx: true,
+			Values: values,
+		})
+		pipe.XAck(ctx, outboxStream, outboxGroup, msg.ID)
+		pipe.XDel(ctx, outboxStream, msg.ID)
+		return nil
+	})
+	return err
+}
+
+func (o *redisOutbox) PruneConsumers(ctx context.Context, keep string, idle time.Duration) error {
+	consumers, err := o.client.XInfoConsumers(ctx, outboxStream, outboxGroup).Result()
+	if err != nil {
+		return err
+	}
+	for _, c := range consumers {
+		// Сообщения ушедшего consumer сначала забирает Reclaim - удалять его раньше нельзя
+		if c.Name == keep || c.Pending > 0 || c.Idle < idle {
+			continue
+		}
+		if err := o.client.XGroupDelConsumer(ctx, outboxStream, outboxGroup, c.Name).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toOutboxMessages(in []redis.XMessage) []OutboxMessage {
+	out := make([]OutboxMessage, 0, len(in))
+	for _, m := range in {
+		key, _ := m.Values["key"].(string)
+		payload, _ := m.Values["payload"].(string)
+		msg := OutboxMessage{ID: m.ID, Key: key, Payload: []byte(payload), Headers: map[string]string{}}
+		for field, v := range m.Values {
+			if name, ok := strings.CutPrefix(field, outboxHeaderPrefix); ok {
+				msg.Headers[name], _ = v.(string)
+			}
+		}
+		out = append(out, msg)
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package redisstorage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bucket is a token bucket holding up to Capacity tokens, refilled evenly over Period
+type Bucket struct {
+	Key      string
+	Capacity int
+	Period   time.Duration
+}
+
+// RateLimitResult reports the outcome of RateLimiter.Take
+type RateLimitResult struct {
+	Allowed bool
+	// Exhausted is the index of the bucket that rejected the request
+	Exhausted int
+	// RetryAfter is how long until the exhausted bucket has a token again
+	RetryAfter time.Duration
+}
+
+// RateLimiter takes tokens from buckets shared by all gateway replicas
+type RateLimiter interface {
+	// Take removes one token from every bucket, or none if any of them is empty
+	Take(ctx context.Context, buckets ...Bucket) (RateLimitResult, error)
+}
+
+// takeScript checks every bucket before consuming, so a request rejected by one
+// bucket does not drain the others. Time comes from the Redis server to keep
+// replicas with skewed clocks consistent.
+//
+// KEYS: bucket keys; ARGV: capacity and period in ms for each key.
+// Returns {0, 0} when allowed, otherwise {1-based index, retry after ms}
+var takeScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local tokens = {}
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[2 * i - 1])
+	local period = tonumber(ARGV[2 * i])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local n = tonumber(state[1])
+	local ts = tonumber(state[2])
+	if n == nil or ts == nil then
+		n = capacity
+		ts = now
+	end
+	n = math.min(capacity, n + math.max(0, now - ts) * capacity / period)
+	if n < 1 then
+		return {i, math.ceil((1 - n) * period / capacity)}
+	end
+	tokens[i] = n
+end
+for i, key in ipairs(KEYS) do
+	redis.call('HSET', key, 'tokens', tostring(tokens[i] - 1), 'ts', now)
+	redis.call('PEXPIRE', key, ARGV[2 * i])
+end
+return {0, 0}
+`)
+
+// redisRateLimiter implements RateLimiter with a Lua token bucket
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+// NewRateLimiter creates a rate limiter on top of an existing redis client
+func NewRateLimiter(client *redis.Client) RateLimiter {
+	return &redisRateLimiter{client: client}
+}
+
+// Take runs takeScript atomically over all buckets
+func (r *redisRateLimiter) Take(ctx context.Context, buckets ...Bucket) (RateLimitResult, error) {
+	if len(buckets) == 0 {
+		return RateLimitResult{Allowed: true}, nil
+	}
+	keys := make([]string, len(buckets))
+	args := make([]interface{}, 0, 2*len(buckets))
+	for i, b := range buckets {
+		keys[i] = b.Key
+		args = append(args, strconv.Itoa(b.Capacity), strconv.FormatInt(b.Period.Milliseconds(), 10))
+	}
+
+	res, err := takeScript.Run(ctx, r.client, keys, args...).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if res[0] == 0 {
+		return RateLimitResult{Allowed: true}, nil
+	}
+	return RateLimitResult{
+		Exhausted:  int(res[0]) - 1,
+		RetryAfter: time.Duration(res[1]) * time.Millisecond,
+	}, nil
+}
@@ -2,43 +2,161 @@ package redisstorage
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	customer "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
 )
 
+// Default cache lifetimes
 const (
+	// cacheSoftTTL: past it an entry is still served, but refreshed in background
+	cacheSoftTTL = time.Minute
+	// cacheTTL: past it an entry is expired and only served if the downstream call fails
 	cacheTTL = time.Minute * 10
+	// cacheStaleTTL: how long expired entries are kept in Redis for stale-if-error
+	cacheStaleTTL = time.Hour * 24
 )
 
+// CacheTTL configures how long cached settings stay fresh, usable and retained
+type CacheTTL struct {
+	Soft  time.Duration
+	Hard  time.Duration
+	Stale time.Duration
+}
+
+// Freshness tells how an entry stored age ago may be used
+func (t CacheTTL) Freshness(age time.Duration) Freshness {
+	switch {
+	case age > t.Hard:
+		return Expired
+	case age > t.Soft:
+		return Stale
+	default:
+		return Fresh
+	}
+}
+
+// DefaultCacheTTL returns the built-in cache lifetimes
+func DefaultCacheTTL() CacheTTL {
+	return CacheTTL{Soft: cacheSoftTTL, Hard: cacheTTL, Stale: cacheStaleTTL}
+}
+
+// Freshness describes how a cache entry may be used
+type Freshness int
+
+const (
+	// Fresh entries are served as is
+	Fresh Freshness = iota
+	// Stale entries are past the soft TTL: serve and revalidate in background
+	Stale
+	// Expired entries are past the hard TTL: serve only if the downstream call fails
+	Expired
+)
+
+// CachedSettings is a cache entry together with its age
+type CachedSettings struct {
+	Settings  *customer.GetUserSettingsResponse
+	StoredAt  time.Time
+	Freshness Freshness
+}
+
+// cacheEnvelope is the value stored in Redis; settings keep the protojson format
+type cacheEnvelope struct {
+	StoredAt time.Time `json:"stored_at"`
+	// Version orders writes of the same user's settings, see settingsVersion
+	Version  string          `json:"version,omitempty"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+// ErrNewerCached is returned by Set and Replace when the cache already holds a newer
+// version of the settings and the write was skipped; the cache is consistent, callers may ignore it
+var ErrNewerCached = errors.New("cache already holds newer settings")
+
 // Storage provides an interface to Redis for get/set/invalidate
 type Storage interface {
-	Get(ctx context.Context, userID string) (*customer.GetUserSettingsResponse, error)
+	Get(ctx context.Context, userID string) (*CachedSettings, error)
+	// Set caches settings read from customer service
 	Set(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error
+	// Replace caches settings just written to customer service in place of every cached copy
+	Replace(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error
 	Invalidate(ctx context.Context, userID string) error
 }
 
+// setScript writes a cache entry unless Redis already holds a newer version of it,
+// so a slow fill with settings read before an update cannot overwrite the update.
+// Versions are fixed-width decimal strings and compare as strings.
+//
+// KEYS: cache key; ARGV: version (empty when unknown), envelope, TTL in ms.
+// Returns 1 when written, 0 when a newer version is cached
+var setScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and ARGV[1] ~= '' then
+	local ok, env = pcall(cjson.decode, current)
+	if ok and type(env) == 'table' and type(env.version) == 'string' and env.version > ARGV[1] then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// TTLSetter is implemented by storages whose cache lifetimes can be changed at runtime
+type TTLSetter interface {
+	SetTTL(ttl CacheTTL)
+}
+
 // redisStorage implements Storage
 type redisStorage struct {
 	client *redis.Client
+	ttl    atomic.Pointer[CacheTTL]
 }
 
-// New creates a new redis storage client
-func New(addr string) (Storage, error) {
+// Connect creates a redis client and verifies the connection
+func Connect(addr string) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{Addr: addr})
+	// Every command becomes a span under the caller's trace
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, err
+	}
 	// verify connection
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		return nil, err
 	}
-	return &redisStorage{client: client}, nil
+	return client, nil
+}
+
+// New creates a new redis storage client
+func New(addr string, ttl CacheTTL) (Storage, error) {
+	client, err := Connect(addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromClient(client, ttl), nil
+}
+
+// NewFromClient creates settings storage on top of an existing redis client
+func NewFromClient(client *redis.Client, ttl CacheTTL) Storage {
+	r := &redisStorage{client: client}
+	r.SetTTL(ttl)
+	return r
+}
+
+// SetTTL changes cache lifetimes; entries already written keep their Redis expiry
+func (r *redisStorage) SetTTL(ttl CacheTTL) {
+	r.ttl.Store(&ttl)
 }
 
-// Get retrieves cached settings from Redis
-func (r *redisStorage) Get(ctx context.Context, userID string) (*customer.GetUserSettingsResponse, error) {
+// Get retrieves cached settings from Redis along with their freshness
+func (r *redisStorage) Get(ctx context.Context, userID string) (*CachedSettings, error) {
 	key := r.key(userID)
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
@@ -47,23 +165,63 @@ func (r *redisStorage) Get(ctx context.Context, userID string) (*customer.GetUse
 	if err != nil {
 		return nil, err
 	}
+	cached, err := decodeCached([]byte(val))
+	if err != nil {
+		slog.WarnContext(ctx, "failed to unmarshal cached settings", "error", err)
+		return nil, err
+	}
+	if cached == nil {
+		return nil, nil
+	}
+	cached.Freshness = r.freshness(time.Since(cached.StoredAt))
+	return cached, nil
+}
+
+// decodeCached parses a cache envelope; entries without settings are reported as a miss (nil, nil)
+func decodeCached(val []byte) (*CachedSettings, error) {
+	var env cacheEnvelope
+	if err := json.Unmarshal(val, &env); err != nil {
+		return nil, err
+	}
+	// записи старого формата (голый protojson без конверта) не содержат поля settings —
+	// считаем их промахом, и они перезапишутся свежими данными
+	if len(env.Settings) == 0 {
+		return nil, nil
+	}
 	var res customer.GetUserSettingsResponse
-	if err := protojson.Unmarshal([]byte(val), &res); err != nil {
-		log.Printf("failed to unmarshal cached value for %s: %v", userID, err)
+	if err := protojson.Unmarshal(env.Settings, &res); err != nil {
 		return nil, err
 	}
-	return &res, nil
+	return &CachedSettings{Settings: &res, StoredAt: env.StoredAt}, nil
 }
 
-// Set stores settings in Redis as JSON
+// Set stores settings in Redis as JSON unless a newer version (by updated_at) is already cached
 func (r *redisStorage) Set(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error {
 	key := r.key(userID)
 	b, err := protojson.Marshal(data)
 	if err != nil {
 		return err
 	}
-	// set with TTL
-	return r.client.Set(ctx, key, string(b), cacheTTL).Err()
+	version := settingsVersion(data)
+	env, err := json.Marshal(cacheEnvelope{StoredAt: time.Now(), Version: version, Settings: b})
+	if err != nil {
+		return err
+	}
+	// keep expired entries around for stale-if-error; freshness is derived from stored_at
+	ttl := r.ttl.Load()
+	written, err := setScript.Run(ctx, r.client, []string{key}, version, string(env), max(ttl.Hard, ttl.Stale).Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if written == 0 {
+		return ErrNewerCached
+	}
+	return nil
+}
+
+// Replace is Set: the version guard already keeps the newest settings
+func (r *redisStorage) Replace(ctx context.Context, userID string, data *customer.GetUserSettingsResponse) error {
+	return r.Set(ctx, userID, data)
 }
 
 // Invalidate removes cache entry for user
@@ -72,6 +230,23 @@ func (r *redisStorage) Invalidate(ctx context.Context, userID string) error {
 	return r.client.Del(ctx, key).Err()
 }
 
+func (r *redisStorage) freshness(age time.Duration) Freshness {
+	return r.ttl.Load().Freshness(age)
+}
+
+// settingsVersion is updated_at in nanoseconds, zero-padded so versions compare as strings.
+// Settings without updated_at have no version and are always written
+func settingsVersion(data *customer.GetUserSettingsResponse) string {
+	if data.GetUpdatedAt() == nil {
+		return ""
+	}
+	ns := data.GetUpdatedAt().AsTime().UnixNano()
+	if ns < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%020d", ns)
+}
+
 func (r *redisStorage) key(userID string) string {
 	return "user:settings:" + userID
 }
@@ -1,6 +1,7 @@
 package redisstorage
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -72,3 +73,53 @@ func marshalSettingsResponse(resp *pb.GetUserSettingsResponse) ([]byte, error) {
 func unmarshalSettingsResponse(b []byte, resp *pb.GetUserSettingsResponse) error {
 	return protojson.Unmarshal(b, resp)
 }
+
+func TestRedisStorage_Freshness(t *testing.T) {
+	r := &redisStorage{}
+	r.SetTTL(CacheTTL{Soft: time.Minute, Hard: 10 * time.Minute, Stale: 24 * time.Hour})
+
+	assert.Equal(t, Fresh, r.freshness(30*time.Second))
+	assert.Equal(t, Stale, r.freshness(5*time.Minute))
+	assert.Equal(t, Expired, r.freshness(time.Hour))
+
+	// Reloaded lifetimes apply to entries already in the cache
+	r.SetTTL(CacheTTL{Soft: 10 * time.Second, Hard: time.Minute, Stale: time.Hour})
+	assert.Equal(t, Stale, r.freshness(30*time.Second))
+	assert.Equal(t, Expired, r.freshness(5*time.Minute))
+}
+
+func TestDecodeCached_LegacyEntryIsMiss(t *testing.T) {
+	// Entries written before the envelope hold the bare protojson response
+	legacy, err := protojson.Marshal(&pb.GetUserSettingsResponse{Theme: "dark"})
+	assert.NoError(t, err)
+	cached, err := decodeCached(legacy)
+	assert.NoError(t, err)
+	assert.Nil(t, cached)
+
+	storedAt := time.Now().Add(-time.Minute).UTC()
+	env, err := json.Marshal(cacheEnvelope{StoredAt: storedAt, Settings: legacy})
+	assert.NoError(t, err)
+	cached, err = decodeCached(env)
+	assert.NoError(t, err)
+	if assert.NotNil(t, cached) {
+		assert.Equal(t, "dark", cached.Settings.Theme)
+		assert.True(t, storedAt.Equal(cached.StoredAt))
+	}
+
+	_, err = decodeCached([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestSettingsVersion_OrdersAsStrings(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	older := settingsVersion(&pb.GetUserSettingsResponse{UpdatedAt: timestamppb.New(base)})
+	newer := settingsVersion(&pb.GetUserSettingsResponse{UpdatedAt: timestamppb.New(base.Add(time.Nanosecond))})
+	muchNewer := settingsVersion(&pb.GetUserSettingsResponse{UpdatedAt: timestamppb.New(base.AddDate(100, 0, 0))})
+
+	// setScript сравнивает версии как строки - длина должна совпадать
+	assert.Len(t, older, 20)
+	assert.Len(t, muchNewer, 20)
+	assert.Less(t, older, newer)
+	assert.Less(t, newer, muchNewer)
+	assert.Empty(t, settingsVersion(&pb.GetUserSettingsResponse{}))
+}
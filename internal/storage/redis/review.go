@@ -0,0 +1,112 @@
+package redisstorage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	reviewpb "api-gateway/api/review"
+)
+
+const (
+	// reviewTTL bounds how long review lifecycle records are kept
+	reviewTTL = time.Hour * 24 * 7
+)
+
+// ReviewStore keeps the lifecycle state of submitted reviews
+type ReviewStore interface {
+	GetReview(ctx context.Context, reviewID string) (*reviewpb.ReviewStatus, error)
+	SetReview(ctx context.Context, review *reviewpb.ReviewStatus) error
+	// WatchReview streams every state stored for reviewID by any gateway replica
+	// until ctx is done, at which point the channel is closed
+	WatchReview(ctx context.Context, reviewID string) (<-chan *reviewpb.ReviewStatus, error)
+}
+
+// redisReviewStore implements ReviewStore
+type redisReviewStore struct {
+	client *redis.Client
+}
+
+// NewReviewStore creates review state storage on top of an existing redis client
+func NewReviewStore(client *redis.Client) ReviewStore {
+	return &redisReviewStore{client: client}
+}
+
+// GetReview returns the stored review state or nil if the review is unknown
+func (r *redisReviewStore) GetReview(ctx context.Context, reviewID string) (*reviewpb.ReviewStatus, error) {
+	val, err := r.client.Get(ctx, r.key(reviewID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res reviewpb.ReviewStatus
+	if err := protojson.Unmarshal([]byte(val), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SetReview stores review state as JSON, keyed by review ID, and publishes it to watchers
+func (r *redisReviewStore) SetReview(ctx context.Context, review *reviewpb.ReviewStatus) error {
+	b, err := protojson.Marshal(review)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(review.ReviewId), string(b), reviewTTL)
+		pipe.Publish(ctx, r.channel(review.ReviewId), string(b))
+		return nil
+	})
+	return err
+}
+
+// WatchReview subscribes to review updates. The subscription is confirmed
+// before returning, so a GetReview issued afterwards can't miss a transition
+func (r *redisReviewStore) WatchReview(ctx context.Context, reviewID string) (<-chan *reviewpb.ReviewStatus, error) {
+	sub := r.client.Subscribe(ctx, r.channel(reviewID))
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	out := make(chan *reviewpb.ReviewStatus)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var review reviewpb.ReviewStatus
+				if err := protojson.Unmarshal([]byte(msg.Payload), &review); err != nil {
+					slog.WarnContext(ctx, "failed to unmarshal review update", "review_id", reviewID, "error", err)
+					continue
+				}
+				select {
+				case out <- &review:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *redisReviewStore) key(reviewID string) string {
+	return "review:status:" + reviewID
+}
+
+func (r *redisReviewStore) channel(reviewID string) string {
+	return "review:updates:" + reviewID
+}
@@ -0,0 +1,256 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/internal/metrics"
+)
+
+// Client certificate policies of a TLS listener
+const (
+	ClientAuthNone          = "none"
+	ClientAuthVerifyIfGiven = "verify-if-given"
+	ClientAuthRequire       = "require"
+)
+
+// Files names the PEM files of a TLS identity. CertFile and KeyFile may be left empty on
+// the client side, where a certificate is only needed for mTLS. CAFile holds the CAs that
+// sign peer certificates: client CAs for a listener, root CAs for a client (empty uses the system roots)
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Reloader keeps a certificate and CA pool loaded from disk and reloads them when the files change,
+// so rotated certificates are picked up by new handshakes without a restart.
+// Established connections keep the certificate they were opened with
+type Reloader struct {
+	name     string
+	files    Files
+	interval time.Duration
+
+	cert atomic.Pointer[tls.Certificate]
+	pool atomic.Pointer[x509.CertPool]
+	// stamps is only touched by load, which runs from New and then from the reload goroutine
+	stamps map[string]stamp
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// stamp identifies a version of a file
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New loads files and returns a reloader that checks them for changes every interval once started.
+// name identifies the identity in logs and metrics
+func New(name string, files Files, interval time.Duration) (*Reloader, error) {
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, fmt.Errorf("%s TLS: certificate and key files must be set together", name)
+	}
+	r := &Reloader{name: name, files: files, interval: interval}
+	if _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start polls the files every interval until Close
+func (r *Reloader) Start() {
+	if r.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			reloaded, err := r.load()
+			switch {
+			case err != nil:
+				// Файлы могут быть на середине замены - оставляем прежний сертификат и пробуем на следующем тике
+				metrics.TLSReloadFailures.WithLabelValues(r.name).Inc()
+				slog.Warn("failed to reload TLS certificate", "tls", r.name, "error", err)
+			case reloaded:
+				slog.Info("reloaded TLS certificate", "tls", r.name)
+			}
+		}
+	}()
+}
+
+// Close stops polling
+func (r *Reloader) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// load reads the files if any of them changed since the last successful load
+func (r *Reloader) load() (bool, error) {
+	stamps := make(map[string]stamp, 3)
+	for _, path := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if path == "" {
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		stamps[path] = stamp{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	if r.stamps != nil && equalStamps(r.stamps, stamps) {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if r.files.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("%s TLS: %w", r.name, err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.files.CAFile != "" {
+		pemData, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return false, fmt.Errorf("%s TLS: %w", r.name, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return false, fmt.Errorf("%s TLS: no certificates in %s", r.name, r.files.CAFile)
+		}
+	}
+
+	if cert != nil {
+		r.cert.Store(cert)
+		metrics.TLSCertificateExpiry.WithLabelValues(r.name).Set(float64(cert.Leaf.NotAfter.Unix()))
+	}
+	if pool != nil {
+		r.pool.Store(pool)
+	}
+	r.stamps = stamps
+	return true, nil
+}
+
+func equalStamps(a, b map[string]stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, s := range a {
+		if other, ok := b[path]; !ok || !s.modTime.Equal(other.modTime) || s.size != other.size {
+			return false
+		}
+	}
+	return true
+}
+
+// ServerConfig returns a listener config presenting the current certificate and verifying
+// client certificates against the CA file according to clientAuth
+func (r *Reloader) ServerConfig(clientAuth string) (*tls.Config, error) {
+	if r.cert.Load() == nil {
+		return nil, fmt.Errorf("%s TLS: a certificate is required to serve TLS", r.name)
+	}
+	var mode tls.ClientAuthType
+	switch clientAuth {
+	case "", ClientAuthNone:
+		mode = tls.NoClientCert
+	case ClientAuthVerifyIfGiven:
+		mode = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		mode = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%s TLS: unknown client auth %q", r.name, clientAuth)
+	}
+	if mode != tls.NoClientCert && r.pool.Load() == nil {
+		return nil, fmt.Errorf("%s TLS: client auth %q needs a client CA file", r.name, clientAuth)
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Конфиг собирается на каждое рукопожатие, чтобы подхватывать перевыпущенные сертификаты и CA
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert.Load()},
+				ClientAuth:   mode,
+				ClientCAs:    r.pool.Load(),
+			}, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a config for dialing serverName. The current certificate, if any, is
+// presented for mTLS, and the server is verified against the current CA pool or the system roots
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.cert.Load(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+		// Стандартная проверка берет RootCAs один раз, поэтому проверяем сами по актуальному пулу
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyServer(cs, r.pool.Load())
+		},
+	}
+}
+
+// LoopbackConfig returns a config for the gateway's own connection to its TLS listener:
+// the server must present exactly the certificate being served, and the same certificate
+// is presented back, so in mTLS mode it must also be acceptable as a client certificate
+func (r *Reloader) LoopbackConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.cert.Load(), nil
+		},
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 || !cs.PeerCertificates[0].Equal(r.cert.Load().Leaf) {
+				return errors.New("loopback TLS: server certificate does not match the served one")
+			}
+			return nil
+		},
+	}
+}
+
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
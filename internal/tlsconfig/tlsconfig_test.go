@@ -0,0 +1,189 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// writeCA writes the CA certificate to dir and returns its path
+func (ca *testCA) writeCA(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+	return path
+}
+
+// issue writes a leaf certificate for localhost with the given serial to dir/name.pem and dir/name-key.pem
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".pem")
+	keyPath := filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+// handshake connects client to server over loopback TCP and returns the client's view of the connection
+func handshake(t *testing.T, server, client *tls.Config) (tls.ConnectionState, error) {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		conn.Close()
+	}()
+
+	c, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer c.Close()
+	// В TLS 1.3 сервер отклоняет клиентский сертификат уже после рукопожатия клиента
+	if _, err := c.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return tls.ConnectionState{}, err
+	}
+	return c.ConnectionState(), nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.writeCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3)
+
+	server, err := New("grpc", Files{CertFile: serverCert, KeyFile: serverKey, CAFile: caPath}, 0)
+	require.NoError(t, err)
+	serverTLS, err := server.ServerConfig(ClientAuthRequire)
+	require.NoError(t, err)
+
+	client, err := New("customer", Files{CertFile: clientCert, KeyFile: clientKey, CAFile: caPath}, 0)
+	require.NoError(t, err)
+	_, err = handshake(t, serverTLS, client.ClientConfig("localhost"))
+	require.NoError(t, err)
+
+	anonymous, err := New("anonymous", Files{CAFile: caPath}, 0)
+	require.NoError(t, err)
+	_, err = handshake(t, serverTLS, anonymous.ClientConfig("localhost"))
+	assert.Error(t, err, "server must reject a client without certificate")
+
+	// Чужой CA - клиент не доверяет серверу
+	otherDir := t.TempDir()
+	other, err := New("other", Files{CAFile: newTestCA(t).writeCA(t, otherDir)}, 0)
+	require.NoError(t, err)
+	_, err = handshake(t, serverTLS, other.ClientConfig("localhost"))
+	assert.Error(t, err)
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caPath := ca.writeCA(t, dir)
+	certPath, keyPath := ca.issue(t, dir, "server", 2)
+
+	r, err := New("grpc", Files{CertFile: certPath, KeyFile: keyPath}, 10*time.Millisecond)
+	require.NoError(t, err)
+	r.Start()
+	defer r.Close()
+	serverTLS, err := r.ServerConfig(ClientAuthNone)
+	require.NoError(t, err)
+	client, err := New("client", Files{CAFile: caPath}, 0)
+	require.NoError(t, err)
+
+	cs, err := handshake(t, serverTLS, client.ClientConfig("localhost"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cs.PeerCertificates[0].SerialNumber.Int64())
+
+	// Ротация: новый сертификат на том же пути
+	ca.issue(t, dir, "server", 4)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certPath, future, future))
+	assert.Eventually(t, func() bool {
+		cs, err := handshake(t, serverTLS, client.ClientConfig("localhost"))
+		return err == nil && cs.PeerCertificates[0].SerialNumber.Int64() == 4
+	}, time.Second, 10*time.Millisecond)
+
+	// Loopback доверяет ровно текущему сертификату
+	_, err = handshake(t, serverTLS, r.LoopbackConfig())
+	assert.NoError(t, err)
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPath, keyPath := ca.issue(t, dir, "server", 2)
+
+	_, err := New("grpc", Files{CertFile: certPath}, 0)
+	assert.Error(t, err, "key without certificate")
+
+	r, err := New("grpc", Files{CertFile: certPath, KeyFile: keyPath}, 0)
+	require.NoError(t, err)
+	_, err = r.ServerConfig(ClientAuthRequire)
+	assert.Error(t, err, "mTLS without client CA")
+	_, err = r.ServerConfig("sometimes")
+	assert.Error(t, err)
+
+	_, err = New("grpc", Files{CAFile: filepath.Join(dir, "missing.pem")}, 0)
+	assert.Error(t, err)
+}
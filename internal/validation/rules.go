@@ -0,0 +1,55 @@
+package validation
+
+import (
+	reviewpb "api-gateway/api/review"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+// Field limits of gateway requests
+const (
+	MaxIDLength         = 128
+	MaxSourceLength     = 64
+	MaxSettingLength    = 64
+	MaxProfileLength    = 256
+	MaxReviewTextLength = 10000
+)
+
+func init() {
+	Register(
+		Required("user_id", (*pb.GetUserSettingsRequest).GetUserId),
+		MaxLen("user_id", MaxIDLength, (*pb.GetUserSettingsRequest).GetUserId),
+	)
+	Register(
+		Required("user_id", (*pb.UpdateUserSettingsRequest).GetUserId),
+		MaxLen("user_id", MaxIDLength, (*pb.UpdateUserSettingsRequest).GetUserId),
+		MaxLen("theme", MaxSettingLength, (*pb.UpdateUserSettingsRequest).GetTheme),
+		MaxLen("picked_model", MaxSettingLength, (*pb.UpdateUserSettingsRequest).GetPickedModel),
+		MaxLen("font", MaxSettingLength, (*pb.UpdateUserSettingsRequest).GetFont),
+	)
+	Register(
+		Required("user_id", (*pb.CreateUserProfileRequest).GetUserId),
+		MaxLen("user_id", MaxIDLength, (*pb.CreateUserProfileRequest).GetUserId),
+		MaxLen("username", MaxProfileLength, (*pb.CreateUserProfileRequest).GetUsername),
+		MaxLen("company_name", MaxProfileLength, (*pb.CreateUserProfileRequest).GetCompanyName),
+		MaxLen("phone_number", MaxIDLength, (*pb.CreateUserProfileRequest).GetPhoneNumber),
+		MaxLen("theme", MaxSettingLength, (*pb.CreateUserProfileRequest).GetTheme),
+		MaxLen("picked_model", MaxSettingLength, (*pb.CreateUserProfileRequest).GetPickedModel),
+		MaxLen("font", MaxSettingLength, (*pb.CreateUserProfileRequest).GetFont),
+	)
+	Register(
+		Required("user_id", (*pb.AnalyzeReviewRequest).GetUserId),
+		MaxLen("user_id", MaxIDLength, (*pb.AnalyzeReviewRequest).GetUserId),
+		Required("text", (*pb.AnalyzeReviewRequest).GetText),
+		MaxLen("text", MaxReviewTextLength, (*pb.AnalyzeReviewRequest).GetText),
+		MaxLen("source", MaxSourceLength, (*pb.AnalyzeReviewRequest).GetSource),
+	)
+	Register(
+		Required("review_id", (*reviewpb.GetReviewStatusRequest).GetReviewId),
+		MaxLen("review_id", MaxIDLength, (*reviewpb.GetReviewStatusRequest).GetReviewId),
+	)
+	Register(
+		Required("review_id", (*reviewpb.WatchReviewRequest).GetReviewId),
+		MaxLen("review_id", MaxIDLength, (*reviewpb.WatchReviewRequest).GetReviewId),
+	)
+}
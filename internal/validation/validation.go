@@ -0,0 +1,93 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Rule checks one field of a request of type T. Check returns a description
+// of what is wrong with the field, or "" when it is valid
+type Rule[T proto.Message] struct {
+	Field string
+	Check func(req T) string
+}
+
+// validator returns the field violations of a request
+type validator func(req proto.Message) []*errdetails.BadRequest_FieldViolation
+
+// registry holds the rules of every validated request type, keyed by message name
+var registry = map[protoreflect.FullName]validator{}
+
+// Register declares the rules of request type T; call it from package initialization only
+func Register[T proto.Message](rules ...Rule[T]) {
+	var zero T
+	registry[zero.ProtoReflect().Descriptor().FullName()] = func(req proto.Message) []*errdetails.BadRequest_FieldViolation {
+		typed := req.(T)
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, r := range rules {
+			if msg := r.Check(typed); msg != "" {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: r.Field, Description: msg})
+			}
+		}
+		return violations
+	}
+}
+
+// Validate checks req against the rules registered for its type. It returns InvalidArgument
+// with an errdetails.BadRequest listing every violated field; requests without rules are valid
+func Validate(req any) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	validate, ok := registry[msg.ProtoReflect().Descriptor().FullName()]
+	if !ok {
+		return nil
+	}
+	violations := validate(msg)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return Invalid(violations...)
+}
+
+// Invalid returns InvalidArgument describing violations, with an errdetails.BadRequest attached
+func Invalid(violations ...*errdetails.BadRequest_FieldViolation) error {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = v.Field + ": " + v.Description
+	}
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(parts, "; "))
+	if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Required rejects an empty or blank field
+func Required[T proto.Message](field string, get func(T) string) Rule[T] {
+	return Rule[T]{Field: field, Check: func(req T) string {
+		if strings.TrimSpace(get(req)) == "" {
+			return "is required"
+		}
+		return ""
+	}}
+}
+
+// MaxLen rejects a field longer than n characters
+func MaxLen[T proto.Message](field string, n int, get func(T) string) Rule[T] {
+	return Rule[T]{Field: field, Check: func(req T) string {
+		if l := utf8.RuneCountInString(get(req)); l > n {
+			return fmt.Sprintf("must be at most %d characters, got %d", n, l)
+		}
+		return ""
+	}}
+}
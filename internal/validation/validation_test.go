@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/Misha-Mayskiy/HNC-proto/gen/go/user"
+)
+
+func violations(t *testing.T, err error) map[string]string {
+	t.Helper()
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	out := map[string]string{}
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				out[v.Field] = v.Description
+			}
+		}
+	}
+	return out
+}
+
+func TestValidate_Valid(t *testing.T) {
+	assert.NoError(t, Validate(&pb.AnalyzeReviewRequest{UserId: "user-1", Text: "Great app", Source: "app_store"}))
+	assert.NoError(t, Validate(&pb.GetUserSettingsRequest{UserId: "u1@example.com"}))
+	// Идентификаторы приходят из внешних систем - набор символов не ограничиваем
+	assert.NoError(t, Validate(&pb.AnalyzeReviewRequest{UserId: "a+b@example.com", Text: "ok", Source: "web/ios"}))
+	// Types without rules and non-proto values are not checked
+	assert.NoError(t, Validate(&pb.GetUserSettingsResponse{}))
+	assert.NoError(t, Validate("not a message"))
+}
+
+func TestValidate_ReportsEveryField(t *testing.T) {
+	err := Validate(&pb.AnalyzeReviewRequest{
+		UserId: strings.Repeat("u", MaxIDLength+1),
+		Text:   strings.Repeat("я", MaxReviewTextLength+1),
+		Source: strings.Repeat("s", MaxSourceLength+1),
+	})
+	v := violations(t, err)
+	assert.Len(t, v, 3)
+	assert.Contains(t, v["user_id"], "at most 128 characters")
+	assert.Contains(t, v["text"], "at most 10000 characters")
+	assert.Contains(t, v["source"], "at most 64 characters")
+	assert.Contains(t, status.Convert(err).Message(), "user_id:")
+}
+
+func TestValidate_Required(t *testing.T) {
+	v := violations(t, Validate(&pb.AnalyzeReviewRequest{Text: " \n"}))
+	assert.Equal(t, "is required", v["user_id"])
+	assert.Equal(t, "is required", v["text"])
+
+	// Nil requests get the same treatment as empty ones
+	var req *pb.UpdateUserSettingsRequest
+	v = violations(t, Validate(req))
+	assert.Equal(t, "is required", v["user_id"])
+}